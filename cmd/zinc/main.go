@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	_ "time/tzdata" // time zone names for the container image, which has no zoneinfo
 
 	"github.com/prabhatsharma/zinc"
 
@@ -13,6 +14,7 @@ import (
 
 	"github.com/bingoohuang/golog"
 	"github.com/prabhatsharma/zinc/pkg/routes"
+	"github.com/prabhatsharma/zinc/pkg/syslog"
 	"github.com/prabhatsharma/zinc/pkg/zutil"
 )
 
@@ -31,6 +33,10 @@ func main() {
 	r.Use(gin.Recovery())
 	routes.SetRoutes(r)
 
+	if err := syslog.Start(); err != nil {
+		log.Fatalf("Failed to start the syslog listeners: %v", err)
+	}
+
 	port := zutil.GetEnvInt("PORT", 4080)
 	addr := fmt.Sprintf(":%d", port)
 	log.Printf("Start to run on address: %s", addr)
@@ -13,9 +13,10 @@ require (
 	github.com/gin-contrib/cors v1.3.1
 	github.com/gin-gonic/gin v1.7.4
 	github.com/google/uuid v1.3.0
-	github.com/jeremywohl/flatten v1.0.1
 	github.com/joho/godotenv v1.3.0
+	github.com/klauspost/compress v1.15.15
 	golang.org/x/crypto v0.0.0-20210817164053-32db794688a5
+	google.golang.org/protobuf v1.27.1
 )
 
 require (
@@ -61,6 +62,5 @@ require (
 	github.com/ugorji/go/codec v1.2.6 // indirect
 	golang.org/x/sys v0.0.0-20210925032602-92d5a993a665 // indirect
 	golang.org/x/text v0.3.7 // indirect
-	google.golang.org/protobuf v1.27.1 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
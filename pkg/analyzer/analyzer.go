@@ -0,0 +1,94 @@
+// Package analyzer provides the text analyzers that can be named in index mappings and queries.
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/blugelabs/bluge/analysis"
+	"github.com/blugelabs/bluge/analysis/analyzer"
+	"github.com/blugelabs/bluge/analysis/lang/ar"
+	"github.com/blugelabs/bluge/analysis/lang/cjk"
+	"github.com/blugelabs/bluge/analysis/lang/ckb"
+	"github.com/blugelabs/bluge/analysis/lang/da"
+	"github.com/blugelabs/bluge/analysis/lang/de"
+	"github.com/blugelabs/bluge/analysis/lang/en"
+	"github.com/blugelabs/bluge/analysis/lang/es"
+	"github.com/blugelabs/bluge/analysis/lang/fa"
+	"github.com/blugelabs/bluge/analysis/lang/fi"
+	"github.com/blugelabs/bluge/analysis/lang/fr"
+	"github.com/blugelabs/bluge/analysis/lang/hi"
+	"github.com/blugelabs/bluge/analysis/lang/hu"
+	"github.com/blugelabs/bluge/analysis/lang/it"
+	"github.com/blugelabs/bluge/analysis/lang/nl"
+	"github.com/blugelabs/bluge/analysis/lang/no"
+	"github.com/blugelabs/bluge/analysis/lang/pt"
+	"github.com/blugelabs/bluge/analysis/lang/ro"
+	"github.com/blugelabs/bluge/analysis/lang/ru"
+	"github.com/blugelabs/bluge/analysis/lang/sv"
+	"github.com/blugelabs/bluge/analysis/lang/tr"
+	"github.com/blugelabs/bluge/analysis/token"
+	"github.com/blugelabs/bluge/analysis/tokenizer"
+)
+
+// Standard is the name of the analyzer used when none is configured.
+const Standard = "standard"
+
+// analyzers by name. Analyzers are stateless and can be shared between fields and goroutines.
+var analyzers = map[string]*analysis.Analyzer{
+	Standard:     analyzer.NewStandardAnalyzer(),
+	"simple":     analyzer.NewSimpleAnalyzer(),
+	"keyword":    analyzer.NewKeywordAnalyzer(),
+	"web":        analyzer.NewWebAnalyzer(),
+	"whitespace": {Tokenizer: tokenizer.NewWhitespaceTokenizer()},
+	"stop": {
+		Tokenizer:    tokenizer.NewLetterTokenizer(),
+		TokenFilters: []analysis.TokenFilter{token.NewLowerCaseFilter(), en.StopWordsFilter()},
+	},
+
+	// language analyzers with stop words and stemming
+	"arabic":     ar.Analyzer(),
+	"cjk":        cjk.Analyzer(),
+	"sorani":     ckb.Analyzer(),
+	"danish":     da.Analyzer(),
+	"german":     de.Analyzer(),
+	"english":    en.NewAnalyzer(),
+	"spanish":    es.Analyzer(),
+	"persian":    fa.Analyzer(),
+	"finnish":    fi.Analyzer(),
+	"french":     fr.Analyzer(),
+	"hindi":      hi.Analyzer(),
+	"hungarian":  hu.Analyzer(),
+	"italian":    it.Analyzer(),
+	"dutch":      nl.Analyzer(),
+	"norwegian":  no.Analyzer(),
+	"portuguese": pt.Analyzer(),
+	"romanian":   ro.Analyzer(),
+	"russian":    ru.Analyzer(),
+	"swedish":    sv.Analyzer(),
+	"turkish":    tr.Analyzer(),
+}
+
+// Get returns the analyzer with the name. An empty name returns the standard analyzer.
+func Get(name string) (*analysis.Analyzer, error) {
+	if name == "" {
+		name = Standard
+	}
+
+	if a, ok := analyzers[name]; ok {
+		return a, nil
+	}
+
+	return nil, fmt.Errorf("unknown analyzer [%s], valid analyzers are %v", name, Names())
+}
+
+// Names returns the sorted names of all analyzers.
+func Names() []string {
+	names := make([]string, 0, len(analyzers))
+	for name := range analyzers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prabhatsharma/zinc/pkg/zutil"
+)
+
+// hecTokens returns the API keys of the Splunk HTTP Event Collector API, a comma separated list in ZINC_HEC_TOKENS.
+func hecTokens() []string {
+	return splitTokens(zutil.GetEnv("ZINC_HEC_TOKENS", ""))
+}
+
+// HECAuth authenticates the requests of the Splunk HTTP Event Collector API. The token is sent as
+// "Authorization: Splunk <token>", or as the password of basic auth. A token is one of the API keys
+// of ZINC_HEC_TOKENS, or the credentials of a Zinc user as user:password.
+// Errors are reported in the format of the Splunk HEC.
+func HECAuth(c *gin.Context) {
+	var token string
+	if user, password, ok := c.Request.BasicAuth(); ok {
+		if _, ok := VerifyUser(user, password); ok {
+			c.Next()
+			return
+		}
+		token = password
+	} else {
+		scheme, value := Cut(c.GetHeader("Authorization"), " ")
+		if value == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"text": "Token is required", "code": 2})
+			return
+		}
+		if !strings.EqualFold(scheme, "Splunk") {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"text": "Invalid authorization", "code": 3})
+			return
+		}
+		token = strings.TrimSpace(value)
+	}
+
+	if VerifyHECToken(token) {
+		c.Next()
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"text": "Invalid token", "code": 4})
+}
+
+// VerifyHECToken reports whether the token is an API key of ZINC_HEC_TOKENS or the user:password of a Zinc user.
+func VerifyHECToken(token string) bool {
+	for _, t := range hecTokens() {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			return true
+		}
+	}
+
+	if user, password := Cut(token, ":"); user != "" && password != "" {
+		_, ok := VerifyUser(user, password)
+		return ok
+	}
+	return false
+}
+
+func splitTokens(s string) []string {
+	var tokens []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
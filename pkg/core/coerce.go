@@ -0,0 +1,64 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Policies for values that do not match the type of their field, set per index in IndexSettings.TypeConflict.
+const (
+	// TypeConflictReject coerces the value if possible, else rejects the document. This is the default.
+	TypeConflictReject = "reject"
+	// TypeConflictIgnore coerces the value if possible, else leaves the field out and lists it in the _ignored field.
+	TypeConflictIgnore = "ignore"
+	// TypeConflictStrict never coerces and rejects the document.
+	TypeConflictStrict = "strict"
+)
+
+var typeConflictPolicies = []string{TypeConflictReject, TypeConflictIgnore, TypeConflictStrict}
+
+// IgnoredField is the keyword field that lists the fields left out of a document because of a type conflict.
+const IgnoredField = "_ignored"
+
+// TypeConflictError is returned when a value cannot be indexed as the type of its field.
+type TypeConflictError struct {
+	Field string
+	Type  string
+	Value interface{}
+}
+
+func (e *TypeConflictError) Error() string {
+	return fmt.Sprintf("field [%s] of type [%s] cannot accept value %v of type %T", e.Field, e.Type, e.Value, e.Value)
+}
+
+// coerce converts the value to the Go type the field type expects, if possible.
+// Values that cannot be converted are returned as is.
+func coerce(typ string, value interface{}) interface{} {
+	switch typ {
+	case FieldTypeText, FieldTypeKeyword:
+		switch v := value.(type) {
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			return strconv.FormatBool(v)
+		}
+	case FieldTypeNumeric:
+		if v, ok := value.(string); ok {
+			if n, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				return n
+			}
+		}
+	case FieldTypeBool:
+		if v, ok := value.(string); ok {
+			switch strings.ToLower(strings.TrimSpace(v)) {
+			case "true":
+				return true
+			case "false":
+				return false
+			}
+		}
+	}
+
+	return value
+}
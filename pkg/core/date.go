@@ -0,0 +1,189 @@
+package core
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// TimestampField is the field every document is indexed with to hold its event time.
+const TimestampField = "@timestamp"
+
+// Named date formats that can be used in IndexSettings.DateFormats besides Go time layouts.
+const (
+	DateFormatEpochSecond = "epoch_second"
+	DateFormatEpochMillis = "epoch_millis"
+)
+
+var dateFormatNames = map[string]string{
+	"rfc3339":     time.RFC3339Nano,
+	"rfc1123":     time.RFC1123,
+	"rfc1123z":    time.RFC1123Z,
+	"rfc822":      time.RFC822,
+	"rfc822z":     time.RFC822Z,
+	"rfc850":      time.RFC850,
+	"ansic":       time.ANSIC,
+	"unixdate":    time.UnixDate,
+	"common_log":  "02/Jan/2006:15:04:05 -0700", // apache/nginx access logs
+	"syslog":      time.Stamp,
+	"iso8601":     "2006-01-02T15:04:05Z0700",
+	"date":        "2006-01-02",
+	"datetime":    "2006-01-02 15:04:05",
+	"datetime_tz": "2006-01-02 15:04:05 -0700",
+}
+
+// defaultDateLayouts are used to detect date strings when a field has no mapping yet.
+// Fractional seconds are accepted after the seconds field of every layout.
+var defaultDateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"02/Jan/2006:15:04:05 -0700",
+	time.RFC1123Z,
+	time.RFC1123,
+	time.ANSIC,
+	time.UnixDate,
+}
+
+// Epoch numbers are detected as dates only from 2000 up to 2100, so that other numbers, like counts or sizes, are not.
+var (
+	minDetectedEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	maxDetectedEpoch = time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// parseDate parses a date string with the custom formats first and the default layouts after them.
+func parseDate(value string, formats []string) (time.Time, bool) {
+	for _, format := range formats {
+		if t, ok := parseDateFormat(value, format); ok {
+			return t, true
+		}
+	}
+
+	return parseDateLayouts(value)
+}
+
+// detectDate reports whether the value of a field without a mapping is a date. Strings are dates if they match one of
+// the formats that are not epoch formats, or a default layout, so numeric strings are never detected as dates.
+// Numbers are dates if the formats have an epoch format and they are an epoch of that format from 2000 up to 2100.
+func detectDate(value interface{}, formats []string) bool {
+	switch v := value.(type) {
+	case string:
+		for _, format := range formats {
+			if format == DateFormatEpochSecond || format == DateFormatEpochMillis {
+				continue
+			}
+			if _, ok := parseDateFormat(v, format); ok {
+				return true
+			}
+		}
+		_, ok := parseDateLayouts(v)
+		return ok
+	case float64:
+		format := epochFormat(formats)
+		if format == "" {
+			return false
+		}
+		t := epochToTime(v, format)
+		return !t.Before(minDetectedEpoch) && t.Before(maxDetectedEpoch)
+	}
+
+	return false
+}
+
+// validDateFormat reports whether the format is a named format or a Go time layout, which has at least one element.
+func validDateFormat(format string) bool {
+	if format == DateFormatEpochSecond || format == DateFormatEpochMillis {
+		return true
+	}
+	if _, ok := dateFormatNames[format]; ok {
+		return true
+	}
+	return format != "" && time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC).Format(format) != format
+}
+
+// parseDateLayouts parses a date string with the default layouts.
+func parseDateLayouts(value string) (time.Time, bool) {
+	for _, layout := range defaultDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func parseDateFormat(value, format string) (time.Time, bool) {
+	return parseDateFormatIn(value, format, time.UTC)
+}
+
+// parseDateFormatIn parses the date string with the format. Dates without a zone are in the location.
+func parseDateFormatIn(value, format string, loc *time.Location) (time.Time, bool) {
+	switch format {
+	case DateFormatEpochSecond, DateFormatEpochMillis:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return epochToTime(n, format), true
+	}
+
+	if layout, ok := dateFormatNames[format]; ok {
+		format = layout
+	}
+
+	t, err := time.ParseInLocation(format, value, loc)
+	return t, err == nil
+}
+
+// epochToTime converts an epoch number to time. Without an explicit epoch format,
+// numbers too large to be seconds of a sensible date are taken as milliseconds.
+func epochToTime(n float64, format string) time.Time {
+	if format == DateFormatEpochMillis || format != DateFormatEpochSecond && math.Abs(n) >= 1e11 {
+		return time.UnixMilli(int64(n)).UTC()
+	}
+
+	sec, frac := math.Modf(n)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC()
+}
+
+// epochFormat returns the epoch format from the formats, or an empty string if there is none.
+func epochFormat(formats []string) string {
+	for _, format := range formats {
+		if format == DateFormatEpochSecond || format == DateFormatEpochMillis {
+			return format
+		}
+	}
+
+	return ""
+}
+
+// eventTime returns the event time of the document from the timestamp field of the index,
+// or the ingest time if the document does not have it.
+func (ind *Index) eventTime(flatDoc map[string][]interface{}) (time.Time, error) {
+	field := ind.Settings.EventTimeField()
+	values := flatDoc[field]
+	if len(values) == 0 {
+		return time.Now(), nil
+	}
+
+	value := values[0]
+	if format := ind.Settings.TimestampFormat; format != "" {
+		switch v := value.(type) {
+		case string:
+			if t, ok := parseDateFormat(v, format); ok {
+				return t, nil
+			}
+		case float64:
+			if format == DateFormatEpochSecond || format == DateFormatEpochMillis {
+				return epochToTime(v, format), nil
+			}
+		}
+	} else if t, ok := ind.toTime(value); ok {
+		return t, nil
+	}
+
+	return time.Now(), &TypeConflictError{Field: field, Type: FieldTypeDate, Value: value}
+}
@@ -1,16 +1,420 @@
 package core
 
-// UpdateDoc inserts or updates a document in the zinc index
-func (ind *Index) UpdateDoc(docID string, doc *map[string]interface{}, mintedID bool) error {
-	d, err := ind.BuildBlugeDocFromJSON(docID, doc)
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/index"
+	v1 "github.com/prabhatsharma/zinc/pkg/meta/v1"
+)
+
+// Results of a document write.
+const (
+	ResultCreated  = "created"
+	ResultUpdated  = "updated"
+	ResultDeleted  = "deleted"
+	ResultNotFound = "not_found"
+	ResultNoop     = "noop"
+)
+
+// ErrDocNotFound is returned when a document to update does not exist.
+var ErrDocNotFound = errors.New("document not found")
+
+// WriteResult describes a document write.
+type WriteResult struct {
+	Result  string   // one of the Result constants
+	Version int64    // version of the document after the write
+	SeqNo   int64    // sequence number of the write
+	Ignored []string // fields left out of the document because of a type conflict
+}
+
+// UpdateDoc inserts or updates a document in the zinc index if it meets the precondition.
+func (ind *Index) UpdateDoc(docID string, doc *map[string]interface{}, mintedID bool, pre Precondition) (WriteResult, error) {
+	return ind.writeDoc(func(w *DocWriter) (WriteResult, error) {
+		return w.Index(docID, *doc, mintedID, pre)
+	})
+}
+
+// PartialUpdateDoc deep merges the partial document into the stored source of the document and indexes the result.
+// If the document does not exist the upsert document is indexed, or the partial document itself with docAsUpsert,
+// else ErrDocNotFound is returned.
+func (ind *Index) PartialUpdateDoc(docID string, partial, upsert map[string]interface{}, docAsUpsert bool, pre Precondition) (WriteResult, error) {
+	return ind.writeDoc(func(w *DocWriter) (WriteResult, error) {
+		return w.Update(docID, partial, upsert, docAsUpsert, pre)
+	})
+}
+
+// DeleteDoc deletes the document from the zinc index if it meets the precondition.
+func (ind *Index) DeleteDoc(docID string, pre Precondition) (WriteResult, error) {
+	return ind.writeDoc(func(w *DocWriter) (WriteResult, error) {
+		return w.Delete(docID, pre)
+	})
+}
+
+// writeDoc runs a single write with its own DocWriter. A write that fails is discarded.
+func (ind *Index) writeDoc(write func(w *DocWriter) (WriteResult, error)) (WriteResult, error) {
+	w := ind.NewDocWriter()
+	result, err := write(w)
+	if err != nil {
+		w.Discard()
+		return result, err
+	}
+
+	return result, w.Flush()
+}
+
+// DocWriter writes documents to an index in a batch. From its first write until Flush it holds the document lock
+// of the index, so the versions and the preconditions it checked stay valid until the batch is applied. As every other
+// write to the index waits for the lock, the documents should be read and prepared before the first write, not between
+// the writes.
+type DocWriter struct {
+	ind     *Index
+	reader  *bluge.Reader
+	batch   *index.Batch
+	size    int
+	pending map[string]pendingDoc // documents written to the batch, by id
+	fields  map[string]string     // fields first seen in the documents of the batch, mapped once it is applied
+}
+
+// pendingDoc is a document written to the batch but not applied yet.
+type pendingDoc struct {
+	version docVersion
+	source  map[string]interface{} // nil if the document was deleted
+}
+
+// NewDocWriter returns a writer for the documents of the index. Call Flush to apply its writes.
+func (ind *Index) NewDocWriter() *DocWriter {
+	return &DocWriter{ind: ind}
+}
+
+// Len returns the number of writes waiting in the batch.
+func (w *DocWriter) Len() int {
+	return w.size
+}
+
+// Flush applies the batch to the index and releases the document lock.
+func (w *DocWriter) Flush() error {
+	if w.batch == nil {
+		return nil
+	}
+
+	defer w.Discard() // releases the lock, and drops the batch if it could not be applied
+
+	return w.apply()
+}
+
+// Discard drops the writes of the batch without applying them and releases the document lock.
+func (w *DocWriter) Discard() {
+	if w.batch == nil {
+		return
+	}
+
+	w.reader.Close()
+	w.reader, w.batch, w.size, w.pending, w.fields = nil, nil, 0, nil, nil
+	w.ind.docLock.Unlock()
+}
+
+// apply applies the batch to the index and maps the new fields of its documents, keeping the document lock.
+func (w *DocWriter) apply() error {
+	if w.size == 0 {
+		return nil
+	}
+
+	if err := w.ind.Writer.Batch(w.batch); err != nil {
+		return err
+	}
+	w.batch.Reset()
+	w.size = 0
+
+	if len(w.fields) == 0 {
+		return nil
+	}
+	fields := w.fields
+	w.fields = make(map[string]string)
+	return w.ind.addFields(fields)
+}
+
+// reserve makes room in the batch for a write of the document. The deletes of a batch only apply to the documents
+// already in the index, so the batch is applied first if it holds an earlier write of the same document.
+func (w *DocWriter) reserve(docID string) error {
+	if _, ok := w.pending[docID]; !ok {
+		return nil
+	}
+
+	return w.apply()
+}
+
+// begin takes the document lock and opens the reader the writes are checked against.
+func (w *DocWriter) begin() error {
+	if w.batch != nil {
+		return nil
+	}
+
+	w.ind.docLock.Lock()
+	reader, err := w.ind.Writer.Reader()
 	if err != nil {
+		w.ind.docLock.Unlock()
 		return err
 	}
 
-	// Finally, update the document on disk
-	if mintedID {
-		return ind.Writer.Insert(d)
+	if !w.ind.seqNoLoaded {
+		if w.ind.seqNo, err = maxSeqNo(reader); err != nil {
+			reader.Close()
+			w.ind.docLock.Unlock()
+			return err
+		}
+		w.ind.seqNoLoaded = true
+	}
+
+	w.reader, w.batch, w.pending, w.fields = reader, index.NewBatch(), make(map[string]pendingDoc), make(map[string]string)
+	return nil
+}
+
+// current returns the version and the source of the document, taking the writes waiting in the batch into account.
+func (w *DocWriter) current(docID string) (docVersion, map[string]interface{}, error) {
+	if p, ok := w.pending[docID]; ok {
+		return p.version, p.source, nil
+	}
+
+	doc, err := w.ind.readDoc(w.reader, docID)
+	if err != nil {
+		return docVersion{}, nil, err
+	}
+
+	source, _ := doc.Source.(map[string]interface{})
+	return docVersion{Found: doc.Found, Version: doc.Version, SeqNo: doc.SeqNo}, source, nil
+}
+
+// Index indexes the document, replacing the document with the same id. mintedID tells that the id was generated
+// for the document, so no document can have it yet.
+func (w *DocWriter) Index(docID string, doc map[string]interface{}, mintedID bool, pre Precondition) (WriteResult, error) {
+	return w.index(docID, doc, mintedID, false, pre)
+}
+
+// Create indexes the document if no document has its id yet, else it returns a *VersionConflictError.
+func (w *DocWriter) Create(docID string, doc map[string]interface{}, mintedID bool) (WriteResult, error) {
+	return w.index(docID, doc, mintedID, true, Precondition{})
+}
+
+func (w *DocWriter) index(docID string, doc map[string]interface{}, mintedID, create bool, pre Precondition) (WriteResult, error) {
+	if err := w.begin(); err != nil {
+		return WriteResult{}, err
+	}
+
+	var current docVersion
+	if !mintedID {
+		var err error
+		if current, _, err = w.current(docID); err != nil {
+			return WriteResult{}, err
+		}
+	}
+	if create && current.Found {
+		return WriteResult{}, &VersionConflictError{ID: docID, Reason: "document already exists"}
+	}
+
+	version, err := pre.check(docID, current)
+	if err != nil {
+		return WriteResult{}, err
+	}
+
+	return w.write(docID, doc, current, version, mintedID)
+}
+
+// Update deep merges the partial document into the source of the document and indexes the result.
+// If the document does not exist the upsert document is indexed, or the partial document itself with docAsUpsert,
+// else ErrDocNotFound is returned.
+func (w *DocWriter) Update(docID string, partial, upsert map[string]interface{}, docAsUpsert bool, pre Precondition) (WriteResult, error) {
+	if err := w.begin(); err != nil {
+		return WriteResult{}, err
+	}
+
+	current, source, err := w.current(docID)
+	if err != nil {
+		return WriteResult{}, err
+	}
+	version, err := pre.check(docID, current)
+	if err != nil {
+		return WriteResult{}, err
+	}
+
+	var doc map[string]interface{}
+	switch {
+	case current.Found:
+		doc = deepCopy(source)
+		if !deepMerge(doc, partial) {
+			return WriteResult{Result: ResultNoop, Version: current.Version, SeqNo: current.SeqNo}, nil
+		}
+	case upsert != nil:
+		doc = upsert
+	case docAsUpsert:
+		doc = partial
+	default:
+		return WriteResult{}, ErrDocNotFound
+	}
+
+	return w.write(docID, doc, current, version, false)
+}
+
+// Delete deletes the document. Deleting a document that does not exist is not an error, its result is ResultNotFound.
+func (w *DocWriter) Delete(docID string, pre Precondition) (WriteResult, error) {
+	if err := w.begin(); err != nil {
+		return WriteResult{}, err
+	}
+
+	current, _, err := w.current(docID)
+	if err != nil {
+		return WriteResult{}, err
+	}
+	version, err := pre.check(docID, current)
+	if err != nil {
+		return WriteResult{}, err
+	}
+	if !current.Found {
+		return WriteResult{Result: ResultNotFound}, nil
+	}
+	if err := w.reserve(docID); err != nil {
+		return WriteResult{}, err
+	}
+
+	w.ind.seqNo++
+	w.batch.Delete(bluge.Identifier(docID))
+	w.size++
+	w.pending[docID] = pendingDoc{version: docVersion{Version: version, SeqNo: w.ind.seqNo}}
+
+	return WriteResult{Result: ResultDeleted, Version: version, SeqNo: w.ind.seqNo}, nil
+}
+
+// write adds the document with its version and the next sequence number of the index to the batch.
+func (w *DocWriter) write(docID string, doc map[string]interface{}, current docVersion, version int64, insert bool) (WriteResult, error) {
+	if err := w.reserve(docID); err != nil {
+		return WriteResult{}, err
+	}
+	bdoc, ignored, err := w.ind.BuildBlugeDocFromJSON(docID, &doc, w.fields)
+	if err != nil {
+		return WriteResult{}, err
+	}
+
+	w.ind.seqNo++
+	bdoc.AddField(bluge.NewNumericField(VersionField, float64(version)).StoreValue().Sortable())
+	bdoc.AddField(bluge.NewNumericField(SeqNoField, float64(w.ind.seqNo)).StoreValue().Sortable())
+
+	if insert {
+		w.batch.Insert(bdoc)
+	} else {
+		w.batch.Update(bdoc.ID(), bdoc)
+	}
+	w.size++
+	w.pending[docID] = pendingDoc{version: docVersion{Found: true, Version: version, SeqNo: w.ind.seqNo}, source: doc}
+
+	result := ResultCreated
+	if current.Found {
+		result = ResultUpdated
+	}
+
+	return WriteResult{Result: result, Version: version, SeqNo: w.ind.seqNo, Ignored: ignored}, nil
+}
+
+// deepMerge merges src into dst: objects are merged recursively, other values replace the ones in dst.
+// It reports whether dst changed.
+func deepMerge(dst, src map[string]interface{}) bool {
+	changed := false
+	for k, v := range src {
+		srcMap, srcIsMap := v.(map[string]interface{})
+		dstMap, dstIsMap := dst[k].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			changed = deepMerge(dstMap, srcMap) || changed
+			continue
+		}
+
+		if old, ok := dst[k]; !ok || !reflect.DeepEqual(old, v) {
+			dst[k] = v
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// deepCopy copies the objects and the arrays of the document, so the copy can be changed without changing the document.
+func deepCopy(doc map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(doc))
+	for k, v := range doc {
+		c[k] = deepCopyValue(v)
+	}
+
+	return c
+}
+
+func deepCopyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return deepCopy(v)
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i := range v {
+			c[i] = deepCopyValue(v[i])
+		}
+		return c
 	}
 
-	return ind.Writer.Update(d.ID(), d)
+	return v
+}
+
+// GetDoc returns the document with the id. The document is not found if Found is false.
+func (ind *Index) GetDoc(docID string) (v1.Doc, error) {
+	docs, err := ind.GetDocs([]string{docID})
+	if err != nil {
+		return v1.Doc{}, err
+	}
+
+	return docs[0], nil
+}
+
+// GetDocs returns the documents with the ids, in the same order, from a single reader.
+func (ind *Index) GetDocs(docIDs []string) ([]v1.Doc, error) {
+	reader, err := ind.Writer.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	docs := make([]v1.Doc, len(docIDs))
+	for i, docID := range docIDs {
+		if docs[i], err = ind.readDoc(reader, docID); err != nil {
+			return nil, err
+		}
+	}
+
+	return docs, nil
+}
+
+// readDoc returns the document with the id from the reader. The document is not found if Found is false.
+func (ind *Index) readDoc(reader *bluge.Reader, docID string) (v1.Doc, error) {
+	doc := v1.Doc{Index: ind.Name, Type: ind.Name, ID: docID}
+
+	query := bluge.NewTermQuery(docID).SetField("_id")
+	dmi, err := reader.Search(context.Background(), bluge.NewTopNSearch(1, query))
+	if err != nil {
+		return doc, err
+	}
+
+	next, err := dmi.Next()
+	if err != nil || next == nil {
+		return doc, err
+	}
+
+	hit, err := ind.newHit(next, nil)
+	if err != nil {
+		return doc, err
+	}
+
+	doc.Found = true
+	doc.Version = hit.Version
+	doc.SeqNo = hit.SeqNo
+	doc.Timestamp = &hit.Timestamp
+	doc.Source = hit.Source
+
+	return doc, nil
 }
@@ -0,0 +1,33 @@
+package core
+
+// flattenDoc flattens the nested objects of the document to dot separated field names, e.g. {"a": {"b": 1}} to "a.b".
+// Arrays do not add the index to the field name: every scalar of an array, also those of nested
+// arrays and of arrays of objects, becomes one more value of the same field.
+// e.g. {"tags": ["a", "b"], "users": [{"name": "x"}, {"name": "y"}]} gives tags: [a b] and users.name: [x y]
+func flattenDoc(doc map[string]interface{}) map[string][]interface{} {
+	flat := make(map[string][]interface{})
+	flattenValue(flat, "", doc)
+
+	return flat
+}
+
+func flattenValue(flat map[string][]interface{}, key string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if key != "" {
+				k = key + "." + k
+			}
+			flattenValue(flat, k, child)
+		}
+	case []interface{}:
+		for _, child := range v {
+			flattenValue(flat, key, child)
+		}
+	case nil: // e.g. "rules": null or "creationTimestamp": null
+	default:
+		if key != "" {
+			flat[key] = append(flat[key], v)
+		}
+	}
+}
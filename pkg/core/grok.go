@@ -0,0 +1,180 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// grokPatterns are the built-in grok patterns, a RE2 compatible subset of the logstash patterns.
+var grokPatterns = map[string]string{
+	"USERNAME":     `[a-zA-Z0-9._-]+`,
+	"USER":         `%{USERNAME}`,
+	"INT":          `[+-]?[0-9]+`,
+	"BASE10NUM":    `[+-]?(?:[0-9]+(?:\.[0-9]+)?|\.[0-9]+)`,
+	"NUMBER":       `%{BASE10NUM}`,
+	"POSINT":       `\b[1-9][0-9]*\b`,
+	"NONNEGINT":    `\b[0-9]+\b`,
+	"WORD":         `\b\w+\b`,
+	"NOTSPACE":     `\S+`,
+	"SPACE":        `\s*`,
+	"DATA":         `.*?`,
+	"GREEDYDATA":   `.*`,
+	"QUOTEDSTRING": `"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'`,
+	"QS":           `%{QUOTEDSTRING}`,
+	"UUID":         `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"IPV4":         `(?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.){3}(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)`,
+	"IPV6":         `(?:[0-9A-Fa-f]{0,4}:){2,7}[0-9A-Fa-f]{0,4}`,
+	"IP":           `%{IPV6}|%{IPV4}`,
+	"HOSTNAME":     `\b[0-9A-Za-z][0-9A-Za-z-]{0,62}(?:\.[0-9A-Za-z][0-9A-Za-z-]{0,62})*\.?`,
+	"IPORHOST":     `%{IP}|%{HOSTNAME}`,
+	"HOSTPORT":     `%{IPORHOST}:%{POSINT}`,
+	"URIPATH":      `(?:/[A-Za-z0-9$.+!*'(){},~:;=@#%&_\-]*)+`,
+	"URIPARAM":     `\?[A-Za-z0-9$.+!*'|(){},~@#%&/=:;_?\-\[\]<>]*`,
+	"URIPATHPARAM": `%{URIPATH}(?:%{URIPARAM})?`,
+	"MONTH": `\b(?:[Jj]an(?:uary|uar)?|[Ff]eb(?:ruary|ruar)?|[Mm](?:a|ä)?r(?:ch|z)?|[Aa]pr(?:il)?|[Mm]a(?:y|i)?|[Jj]un(?:e|i)?|` +
+		`[Jj]ul(?:y|i)?|[Aa]ug(?:ust)?|[Ss]ep(?:tember)?|[Oo](?:c|k)?t(?:ober)?|[Nn]ov(?:ember)?|[Dd]e(?:c|z)(?:ember)?)\b`,
+	"MONTHNUM":          `0?[1-9]|1[0-2]`,
+	"MONTHDAY":          `0[1-9]|[12][0-9]|3[01]|[1-9]`,
+	"DAY":               `Mon(?:day)?|Tue(?:sday)?|Wed(?:nesday)?|Thu(?:rsday)?|Fri(?:day)?|Sat(?:urday)?|Sun(?:day)?`,
+	"YEAR":              `(?:\d\d){1,2}`,
+	"HOUR":              `2[0123]|[01]?[0-9]`,
+	"MINUTE":            `[0-5][0-9]`,
+	"SECOND":            `(?:[0-5]?[0-9]|60)(?:[:.,][0-9]+)?`,
+	"TIME":              `%{HOUR}:%{MINUTE}(?::%{SECOND})?`,
+	"ISO8601_TIMEZONE":  `Z|[+-]%{HOUR}(?::?%{MINUTE})`,
+	"TIMESTAMP_ISO8601": `%{YEAR}-%{MONTHNUM}-%{MONTHDAY}[T ]%{HOUR}:?%{MINUTE}(?::?%{SECOND})?%{ISO8601_TIMEZONE}?`,
+	"HTTPDATE":          `%{MONTHDAY}/%{MONTH}/%{YEAR}:%{TIME} %{INT}`,
+	"SYSLOGTIMESTAMP":   `%{MONTH} +%{MONTHDAY} %{TIME}`,
+	"LOGLEVEL": `[Aa]lert|ALERT|[Tt]race|TRACE|[Dd]ebug|DEBUG|[Nn]otice|NOTICE|[Ii]nfo|INFO|[Ww]arn?(?:ing)?|WARN?(?:ING)?|` +
+		`[Ee]rr?(?:or)?|ERR?(?:OR)?|[Cc]rit?(?:ical)?|CRIT?(?:ICAL)?|[Ff]atal|FATAL|[Ss]evere|SEVERE|EMERG(?:ENCY)?|[Ee]merg(?:ency)?`,
+	"COMMONAPACHELOG": `%{IPORHOST:clientip} %{USER:ident} %{USER:auth} \[%{HTTPDATE:timestamp}\] ` +
+		`"(?:%{WORD:verb} %{NOTSPACE:request}(?: HTTP/%{NUMBER:httpversion})?|%{DATA:rawrequest})" %{NUMBER:response:int} (?:%{NUMBER:bytes:int}|-)`,
+	"COMBINEDAPACHELOG": `%{COMMONAPACHELOG} %{QS:referrer} %{QS:agent}`,
+}
+
+// grokRegexp matches %{SYNTAX}, %{SYNTAX:semantic} and %{SYNTAX:semantic:type} in a grok pattern.
+var grokRegexp = regexp.MustCompile(`%\{(\w+)(?::([\w.@\-]+))?(?::(int|float))?\}`)
+
+// maxGrokDepth limits the nesting of patterns, to catch patterns that refer to themselves.
+const maxGrokDepth = 20
+
+// extractPattern is a compiled grok or regex pattern that extracts fields from a string.
+type extractPattern struct {
+	re     *regexp.Regexp
+	fields map[string]extractField // by group name
+}
+
+type extractField struct {
+	name string
+	typ  string // int, float or empty for string
+}
+
+// compileGrok compiles the grok pattern, expanding the custom and the built-in patterns it refers to.
+func compileGrok(pattern string, definitions map[string]string) (*extractPattern, error) {
+	p := &extractPattern{fields: make(map[string]extractField)}
+
+	expanded, err := p.expandGrok(pattern, definitions, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.re, err = regexp.Compile(expanded); err != nil {
+		return nil, fmt.Errorf("grok pattern [%s]: %v", pattern, err)
+	}
+
+	return p, nil
+}
+
+func (p *extractPattern) expandGrok(pattern string, definitions map[string]string, depth int) (string, error) {
+	if depth > maxGrokDepth {
+		return "", fmt.Errorf("grok pattern [%s] is nested too deep", pattern)
+	}
+
+	var err error
+	expanded := grokRegexp.ReplaceAllStringFunc(pattern, func(m string) string {
+		if err != nil {
+			return ""
+		}
+
+		sub := grokRegexp.FindStringSubmatch(m)
+		syntax, semantic, typ := sub[1], sub[2], sub[3]
+
+		definition, ok := definitions[syntax]
+		if !ok {
+			definition, ok = grokPatterns[syntax]
+		}
+		if !ok {
+			err = fmt.Errorf("unknown grok pattern [%s]", syntax)
+			return ""
+		}
+
+		var inner string
+		if inner, err = p.expandGrok(definition, definitions, depth+1); err != nil {
+			return ""
+		}
+		if semantic == "" {
+			return "(?:" + inner + ")"
+		}
+
+		group := "g" + strconv.Itoa(len(p.fields))
+		p.fields[group] = extractField{name: semantic, typ: typ}
+		return "(?P<" + group + ">" + inner + ")"
+	})
+
+	return expanded, err
+}
+
+// compileRegex compiles the regular expression. Its named groups become the fields.
+func compileRegex(pattern string) (*extractPattern, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("regex pattern [%s]: %v", pattern, err)
+	}
+
+	p := &extractPattern{re: re, fields: make(map[string]extractField)}
+	for _, name := range re.SubexpNames() {
+		if name != "" {
+			p.fields[name] = extractField{name: name}
+		}
+	}
+	if len(p.fields) == 0 {
+		return nil, fmt.Errorf("regex pattern [%s] has no named groups", pattern)
+	}
+
+	return p, nil
+}
+
+// extract returns the fields of the string if it matches. Optional groups that did not match are left out.
+func (p *extractPattern) extract(s string) (map[string]interface{}, bool) {
+	loc := p.re.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return nil, false
+	}
+
+	result := make(map[string]interface{}, len(p.fields))
+	for i, group := range p.re.SubexpNames() {
+		field, ok := p.fields[group]
+		if !ok || loc[2*i] < 0 {
+			continue
+		}
+
+		value := s[loc[2*i]:loc[2*i+1]]
+		switch field.typ {
+		case "int":
+			if n, err := strconv.ParseInt(strings.TrimPrefix(value, "+"), 10, 64); err == nil {
+				result[field.name] = float64(n)
+				continue
+			}
+		case "float":
+			if n, err := strconv.ParseFloat(value, 64); err == nil {
+				result[field.name] = n
+				continue
+			}
+		}
+		result[field.name] = value
+	}
+
+	return result, true
+}
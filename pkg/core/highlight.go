@@ -0,0 +1,139 @@
+package core
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/highlight"
+	"github.com/prabhatsharma/zinc/pkg/analyzer"
+	v1 "github.com/prabhatsharma/zinc/pkg/meta/v1"
+)
+
+// Defaults of the highlight options of a search, the same as Elasticsearch.
+const (
+	defaultFragmentSize      = 100
+	defaultNumberOfFragments = 5
+	fragmentSeparator        = "…"
+)
+
+// hitHighlighter highlights the terms that matched the query in the fields of the hits of a search.
+type hitHighlighter struct {
+	ind       *Index
+	fields    []string // field names, with * wildcards
+	formatter highlight.FragmentFormatter
+	size      int
+	number    int // 0 highlights the whole value
+}
+
+// newHitHighlighter returns the highlighter for the highlight options of a search, or nil if no fields are highlighted.
+func (ind *Index) newHitHighlighter(h v1.QueryHighlight) (*hitHighlighter, error) {
+	if len(h.Fields) == 0 {
+		return nil, nil
+	}
+	hl := &hitHighlighter{ind: ind, fields: h.Fields, size: defaultFragmentSize, number: defaultNumberOfFragments}
+	if h.FragmentSize != nil {
+		if *h.FragmentSize <= 0 {
+			return nil, fmt.Errorf("highlight fragment_size must be greater than 0")
+		}
+		hl.size = *h.FragmentSize
+	}
+	if h.NumberOfFragments != nil {
+		if *h.NumberOfFragments < 0 {
+			return nil, fmt.Errorf("highlight number_of_fragments cannot be negative")
+		}
+		hl.number = *h.NumberOfFragments
+	}
+
+	switch h.Style {
+	case "", "html":
+		pre, post := h.PreTag, h.PostTag
+		if pre == "" && post == "" {
+			pre, post = "<mark>", "</mark>"
+		}
+		hl.formatter = highlight.NewHTMLFragmentFormatterTags(pre, post)
+	case "ansi":
+		hl.formatter = highlight.NewANSIFragmentFormatter()
+	default:
+		return nil, fmt.Errorf("unknown highlight style [%s], valid styles are html and ansi", h.Style)
+	}
+
+	return hl, nil
+}
+
+// highlight returns the highlighted fragments of the text and keyword fields of the document. The locations of
+// the match tell the terms that matched in each field, also through _all, and the values of the field are analyzed
+// again to find them, as the locations of a field with many values do not tell the value.
+func (hl *hitHighlighter) highlight(match *search.DocumentMatch, source map[string]interface{}) map[string][]string {
+	if len(match.Locations) == 0 {
+		return nil
+	}
+
+	fragments := make(map[string][]string)
+	for field, values := range flattenDoc(source) {
+		if !matchAny(hl.fields, field) {
+			continue
+		}
+		terms := make(map[string]bool)
+		for term := range match.Locations[field] {
+			terms[term] = true
+		}
+		if len(terms) == 0 {
+			continue
+		}
+
+		for _, value := range values {
+			s, ok := value.(string)
+			if !ok {
+				continue
+			}
+			tlm := hl.termLocations(field, s, terms)
+			if len(tlm) == 0 {
+				continue
+			}
+
+			size, number := hl.size, hl.number
+			if number == 0 {
+				size, number = utf8.RuneCountInString(s), 1
+			}
+			if n := len(fragments[field]); hl.number > 0 && n+number > hl.number {
+				number = hl.number - n
+			}
+			h := highlight.NewSimpleHighlighter(highlight.NewSimpleFragmenterSized(size), hl.formatter, fragmentSeparator)
+			fragments[field] = append(fragments[field], h.BestFragments(tlm, []byte(s), number)...)
+			if hl.number > 0 && len(fragments[field]) >= hl.number {
+				break
+			}
+		}
+	}
+
+	if len(fragments) == 0 {
+		return nil
+	}
+	return fragments
+}
+
+// termLocations returns the locations of the terms in the value of the field. Text values are analyzed with the
+// analyzer of the field, keyword values are a single term.
+func (hl *hitHighlighter) termLocations(field, value string, terms map[string]bool) search.TermLocationMap {
+	tlm := make(search.TermLocationMap)
+	switch hl.ind.CachedMapping[field] {
+	case FieldTypeText:
+		a, err := analyzer.Get(hl.ind.CachedAnalyzers[field])
+		if err != nil {
+			return nil
+		}
+		pos := 0
+		for _, token := range a.Analyze([]byte(value)) {
+			pos += token.PositionIncr
+			if term := string(token.Term); terms[term] {
+				tlm[term] = append(tlm[term], &search.Location{Pos: pos, Start: token.Start, End: token.End})
+			}
+		}
+	case FieldTypeKeyword:
+		if terms[value] {
+			tlm[value] = search.Locations{&search.Location{Pos: 1, Start: 0, End: len(value)}}
+		}
+	}
+	return tlm
+}
@@ -0,0 +1,220 @@
+package core
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/blugelabs/bluge/search"
+	v1 "github.com/prabhatsharma/zinc/pkg/meta/v1"
+	"github.com/prabhatsharma/zinc/pkg/uquery"
+)
+
+// hitOptions select what the hits of a search return besides the id and the score.
+type hitOptions struct {
+	source      v1.SourceFilter
+	highlighter *hitHighlighter
+	// docValues are the requested fields that have doc values: keyword, numeric, date and bool fields,
+	// stored are the requested stored fields and text are the requested text fields, read from the _source.
+	docValues []string
+	stored    map[string]bool
+	text      []string
+	types     map[string]string
+	// sortValues returns the sort values of the hits, to page with search_after
+	sortValues bool
+}
+
+// newHitOptions returns the hit options of the query. Without hit options hits return the full _source.
+func (ind *Index) newHitOptions(q v1.ZincQuery) (*hitOptions, error) {
+	for _, pattern := range append(append(append([]string{}, q.Source.Includes...), q.Source.Excludes...), q.Fields...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid field pattern [%s]", pattern)
+		}
+	}
+
+	hl, err := ind.newHitHighlighter(q.Highlight)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &hitOptions{
+		source:      q.Source,
+		highlighter: hl,
+		stored:      make(map[string]bool),
+		types:       ind.CachedMapping,
+		sortValues:  len(q.SortFields) > 0,
+	}
+	for field, typ := range ind.CachedMapping {
+		if !matchAny(q.Fields, field) {
+			continue
+		}
+		switch typ {
+		case FieldTypeKeyword, FieldTypeNumeric, FieldTypeDate, fieldTypeTime, FieldTypeBool:
+			opts.docValues = append(opts.docValues, field)
+		case FieldTypeStored:
+			opts.stored[field] = true
+		case FieldTypeText:
+			opts.text = append(opts.text, field)
+		}
+	}
+	if matchAny(q.Fields, TimestampField) {
+		opts.docValues = append(opts.docValues, TimestampField)
+	}
+	sort.Strings(opts.docValues)
+	sort.Strings(opts.text)
+
+	return opts, nil
+}
+
+// decodeSource reports whether the hits need the decoded _source.
+func (opts *hitOptions) decodeSource() bool {
+	return opts == nil || !opts.source.Disabled || opts.highlighter != nil || len(opts.text) > 0
+}
+
+// apply sets the fields, the filtered _source, the highlights and the sort values of the hit from the document match and its
+// decoded _source. stored are the values of the requested stored fields.
+func (opts *hitOptions) apply(hit *v1.Hit, match *search.DocumentMatch, source map[string]interface{}, stored map[string][]interface{}) error {
+	fields := make(map[string][]interface{}, len(stored))
+	for field, values := range stored {
+		fields[field] = values
+	}
+	if len(opts.docValues) > 0 {
+		// bluge loads doc values for sorts and aggregations only, load those of the fields for the hit
+		if err := match.LoadDocumentValues(search.NewSearchContext(0, 0), opts.docValues); err != nil {
+			return err
+		}
+		for _, field := range opts.docValues {
+			if values := docValues(match, field, opts.typ(field)); len(values) > 0 {
+				fields[field] = values
+			}
+		}
+	}
+	if len(opts.text) > 0 {
+		flat := flattenDoc(source)
+		for _, field := range opts.text {
+			if values := flat[field]; len(values) > 0 {
+				fields[field] = values
+			}
+		}
+	}
+	if len(fields) > 0 {
+		hit.Fields = fields
+	}
+
+	if opts.sortValues {
+		hit.Sort = uquery.EncodeSortValues(match.SortValue)
+	}
+
+	if opts.highlighter != nil && source != nil {
+		hit.Highlight = opts.highlighter.highlight(match, source)
+	}
+
+	switch {
+	case opts.source.Disabled:
+		hit.Source = nil
+	case len(opts.source.Includes) > 0 || len(opts.source.Excludes) > 0:
+		filtered, _ := opts.filterSource(source, "", len(opts.source.Includes) == 0).(map[string]interface{})
+		if filtered == nil {
+			filtered = make(map[string]interface{})
+		}
+		hit.Source = filtered
+	}
+
+	return nil
+}
+
+// typ returns the type of the field.
+func (opts *hitOptions) typ(field string) string {
+	if field == TimestampField {
+		return FieldTypeDate
+	}
+	return opts.types[field]
+}
+
+// filterSource returns the value of the field of the _source without the excluded fields and, unless included,
+// without the fields that are not included. Objects left without fields are left out, unless included.
+func (opts *hitOptions) filterSource(value interface{}, field string, included bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		filtered := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			childField := key
+			if field != "" {
+				childField = field + "." + key
+			}
+			if matchAny(opts.source.Excludes, childField) {
+				continue
+			}
+			if c := opts.filterSource(child, childField, included || matchAny(opts.source.Includes, childField)); c != nil {
+				filtered[key] = c
+			}
+		}
+		if len(filtered) == 0 && !included {
+			return nil
+		}
+		return filtered
+	case []interface{}:
+		var filtered []interface{}
+		for _, child := range v {
+			if c := opts.filterSource(child, field, included); c != nil {
+				filtered = append(filtered, c)
+			}
+		}
+		if len(filtered) == 0 && !included {
+			return nil
+		}
+		return filtered
+	}
+
+	if !included {
+		return nil
+	}
+	return value
+}
+
+// docValues returns the distinct doc values of the field of the document match. The doc values of a field can be
+// loaded more than once, for sorts and for the fields of the hit.
+func docValues(match *search.DocumentMatch, field, typ string) []interface{} {
+	var values []interface{}
+	seen := make(map[interface{}]bool)
+	add := func(value interface{}) {
+		if !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+
+	src := search.Field(field)
+	switch typ {
+	case FieldTypeNumeric:
+		for _, n := range src.Numbers(match) {
+			add(n)
+		}
+	case FieldTypeDate, fieldTypeTime:
+		for _, t := range src.Dates(match) {
+			add(t.UTC().Format(time.RFC3339Nano))
+		}
+	case FieldTypeBool:
+		for _, value := range match.DocValues(field) {
+			b, _ := strconv.ParseBool(string(value))
+			add(b)
+		}
+	default:
+		for _, value := range match.DocValues(field) {
+			add(string(value))
+		}
+	}
+	return values
+}
+
+// matchAny reports whether the field matches one of the patterns.
+func matchAny(patterns []string, field string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, field); ok {
+			return true
+		}
+	}
+	return false
+}
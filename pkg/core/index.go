@@ -3,100 +3,147 @@ package core
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
-	"reflect"
-	"strconv"
-	"time"
+	"os"
+	"path/filepath"
 
-	"github.com/jeremywohl/flatten"
 	"github.com/prabhatsharma/zinc/pkg/zutil"
 
 	"github.com/blugelabs/bluge"
 )
 
-// BuildBlugeDocFromJSON returns the bluge document for the json document. It also updates the mapping for the fields if not found.
-// If no mappings are found, it creates te mapping for all the encountered fields. If mapping for some fields is found but not for others
-// then it creates the mapping for the missing fields.
-func (ind *Index) BuildBlugeDocFromJSON(docID string, doc *map[string]interface{}) (*bluge.Document, error) {
-	// Pick the index mapping from the cache if it already exists
+// BuildBlugeDocFromJSON returns the bluge document for the json document.
+// Fields without a mapping in the index or in newFields are inferred from their first value. Once the document is built
+// the inferred fields are added to newFields, for the caller to map them once the document is indexed, so that a
+// rejected document leaves the mapping unchanged.
+// Values that do not match the type of their field are handled by the type conflict policy of the index. The names of the fields
+// left out of the document by the ignore policy are returned.
+func (ind *Index) BuildBlugeDocFromJSON(docID string, doc *map[string]interface{}, newFields map[string]string) (*bluge.Document, []string, error) {
 	indexMapping := ind.CachedMapping
-	if indexMapping == nil {
-		indexMapping = make(map[string]string)
-	}
+	inferred := make(map[string]string)
 
-	flatDoc, _ := flatten.Flatten(*doc, "", flatten.DotStyle)
+	flatDoc := flattenDoc(*doc)
 
 	// Create a new bluge document
 	bdoc := bluge.NewDocument(docID)
 
-	indexMappingNeedsUpdate := false
-
-	// Iterate through each field and add it to the bluge document
-	for key, value := range flatDoc {
-		if _, ok := indexMapping[key]; !ok {
-			// Assign auto inferred type for the new key
-			// fmt.Println("Missing Existing Key in index: ", key)
-
-			// Use reflection to find the type of the value.
-			// Bluge requires the field type to be specified.
-
-			if value != nil { // value could be just {} in the json data or e.g. "rules": null or "creationTimestamp": null, etc.
-				v := reflect.ValueOf(value)
-
-				// try to find the type of the value and use it to define default mapping
-				switch v.Type().String() {
-				case "string":
-					indexMapping[key] = "text"
-				case "float64":
-					indexMapping[key] = "numeric"
-				case "bool":
-					indexMapping[key] = "keyword"
-				case "time.Time":
-					indexMapping[key] = "time"
-				}
-
-				indexMappingNeedsUpdate = true
+	var ignored []string
+
+	// ignoreConflict leaves the field of a type conflict out of the document if the policy of the index allows it
+	ignoreConflict := func(err error) bool {
+		var conflict *TypeConflictError
+		if ind.Settings.TypeConflictPolicy() != TypeConflictIgnore || !errors.As(err, &conflict) {
+			return false
+		}
+
+		ignored = append(ignored, conflict.Field)
+		bdoc.AddField(bluge.NewKeywordField(IgnoredField, conflict.Field).StoreValue())
+		return true
+	}
+
+	timestamp, err := ind.eventTime(flatDoc)
+	if err != nil && !ignoreConflict(err) {
+		return nil, nil, err
+	}
+	err = nil
+
+	// Iterate through each field and add all of its values to the bluge document
+	for key, values := range flatDoc {
+		if key == TimestampField { // indexed below as the event time
+			continue
+		}
+
+		fieldType, ok := indexMapping[key]
+		if !ok {
+			fieldType, ok = newFields[key]
+		}
+		if !ok {
+			// Assign auto inferred type for the new key from its first value. Fields declared via the mapping API are never inferred.
+			if fieldType = ind.inferFieldType(values[0]); fieldType == "" {
+				continue
 			}
+
+			inferred[key] = fieldType
 		}
 
-		if value != nil {
-			switch indexMapping[key] {
-			case "text": // found using existing index mapping
-				f := bluge.NewTextField(key, value.(string)).SearchTermPositions()
-				bdoc.AddField(f)
-			case "numeric": // found using existing index mapping
-				f := bluge.NewNumericField(key, value.(float64))
-				bdoc.AddField(f)
-			case "keyword": // found using existing index mapping
-				f := bluge.NewKeywordField(key, strconv.FormatBool(value.(bool)))
-				bdoc.AddField(f)
-			case "time": // found using existing index mapping
-				f := bluge.NewDateTimeField(key, value.(time.Time))
-				bdoc.AddField(f)
+		fields := make([]bluge.Field, 0, len(values))
+		for _, value := range values {
+			var f bluge.Field
+			if f, err = ind.newBlugeField(key, fieldType, value); err != nil {
+				break
 			}
+			if f != nil {
+				fields = append(fields, f)
+			}
+		}
+
+		if err != nil && ignoreConflict(err) {
+			err = nil
+			continue
+		}
+		if err != nil {
+			break
+		}
+
+		for _, f := range fields {
+			bdoc.AddField(f)
 		}
 	}
 
-	if indexMappingNeedsUpdate {
-		ind.SetMapping(indexMapping)
+	if err != nil {
+		return nil, nil, err
+	}
+	for field, typ := range inferred {
+		newFields[field] = typ
 	}
 
 	docByteVal, _ := json.Marshal(*doc)
-	bdoc.AddField(bluge.NewDateTimeField("@timestamp", time.Now()).StoreValue())
+	bdoc.AddField(bluge.NewDateTimeField(TimestampField, timestamp).StoreValue().Sortable())
 	bdoc.AddField(bluge.NewStoredOnlyField("_source", docByteVal))
-	bdoc.AddField(bluge.NewCompositeFieldExcluding("_all", nil)) // Add _all field that can be used for search
+	bdoc.AddField(bluge.NewCompositeFieldExcluding("_all", []string{VersionField, SeqNoField})) // Add _all field that can be used for search
+
+	return bdoc, ignored, nil
+}
+
+// addFields maps the new fields in the mapping of the index, keeping the fields already mapped.
+func (ind *Index) addFields(newFields map[string]string) error {
+	indexMapping := make(map[string]string, len(ind.CachedMapping)+len(newFields))
+	for field, typ := range newFields {
+		indexMapping[field] = typ
+	}
+	for field, typ := range ind.CachedMapping {
+		indexMapping[field] = typ
+	}
 
-	return bdoc, nil
+	return ind.SetMapping(indexMapping)
 }
 
 // SetMapping Saves the mapping of the index to _index_mapping index
 // index: Name of the index for which the mapping needs to be saved
 // iMap: a map of the fields at specify name and type of the field. e.g. movietitle: string
 func (ind *Index) SetMapping(iMap map[string]string) error {
+	if err := setStoredFields(ind.Name, iMap); err != nil {
+		return err
+	}
+
+	// update in the cache
+	ind.CachedMapping = iMap
+
+	return nil
+}
+
+// GetStoredMapping returns the mappings of all the indexes from _index_mapping system index
+func (ind *Index) GetStoredMapping() (map[string]string, error) {
+	return getStoredFields(ind.Name)
+}
+
+// setStoredFields saves the fields as a document with the id to the _index_mapping system index
+func setStoredFields(docID string, fields map[string]string) error {
 	// Create a new bluge document
-	bdoc := bluge.NewDocument(ind.Name)
+	bdoc := bluge.NewDocument(docID)
 
-	for k, v := range iMap {
+	for k, v := range fields {
 		bdoc.AddField(bluge.NewTextField(k, v).StoreValue())
 	}
 
@@ -110,51 +157,72 @@ func (ind *Index) SetMapping(iMap map[string]string) error {
 		return err
 	}
 
-	// update in the cache
-	ind.CachedMapping = iMap
-
 	return nil
 }
 
-// GetStoredMapping returns the mappings of all the indexes from _index_mapping system index
-func (ind *Index) GetStoredMapping() (map[string]string, error) {
-	config := bluge.DefaultConfig(zutil.GetDataDir() + "/_index_mapping")
+// getStoredFields returns the fields of the document with the id from the _index_mapping system index
+func getStoredFields(docID string) (map[string]string, error) {
+	config := bluge.DefaultConfig(zutil.GetDataDir() + "/" + SystemIndexMapping)
 	reader, err := bluge.OpenReader(config)
 	if err != nil {
 		return nil, nil // probably no system index available
 		// log.Fatalf("GetIndexMapping: unable to open reader: %v", err)
 	}
+	defer reader.Close()
 
-	// search for the index mapping _index_mapping index
-	query := bluge.NewTermQuery(ind.Name).SetField("_id")
+	// search for the document in _index_mapping index
+	query := bluge.NewTermQuery(docID).SetField("_id")
 	searchRequest := bluge.NewTopNSearch(1, query) // Should get just 1 result at max
 
 	dmi, err := reader.Search(context.Background(), searchRequest)
 	if err != nil {
 		log.Printf("error executing search: %v", err)
+		return nil, err
 	}
 
 	next, err := dmi.Next()
-	if err != nil {
+	if err != nil || next == nil {
 		return nil, err
 	}
 
-	if next != nil {
-		result := make(map[string]string)
-
-		err = next.VisitStoredFields(func(field string, value []byte) bool {
-			result[field] = string(value)
+	result := make(map[string]string)
+	err = next.VisitStoredFields(func(field string, value []byte) bool {
+		if field == "_id" {
 			return true
-		})
-		if err != nil {
-			return nil, err
 		}
+		result[field] = string(value)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		return result, nil
+	return result, nil
+}
 
+// DocCount returns the number of documents of the index.
+func (ind *Index) DocCount() (uint64, error) {
+	reader, err := ind.Writer.Reader()
+	if err != nil {
+		return 0, err
 	}
+	defer reader.Close()
 
-	reader.Close()
+	return reader.Count()
+}
 
-	return nil, nil
+// StorageSize returns the size in bytes of the files of an index stored on disk, or 0 for other storage types.
+func (ind *Index) StorageSize() int64 {
+	if ind.StorageType != Disk {
+		return 0
+	}
+
+	var size int64
+	filepath.Walk(zutil.GetDataDir()+"/"+ind.Name, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
 }
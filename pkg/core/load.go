@@ -11,11 +11,13 @@ import (
 )
 
 const (
-	SystemIndexUsers   string = "_users"
-	SystemIndexMapping string = "_index_mapping"
+	SystemIndexUsers     string = "_users"
+	SystemIndexMapping   string = "_index_mapping"
+	SystemIndexSettings  string = "_index_settings"
+	SystemIndexPipelines string = "_pipelines"
 )
 
-var systemIndexList = []string{SystemIndexUsers, SystemIndexMapping}
+var systemIndexList = []string{SystemIndexUsers, SystemIndexMapping, SystemIndexSettings, SystemIndexPipelines}
 
 func LoadZincSystemIndexes() (map[string]*Index, error) {
 	log.Print("Loading system indexes...")
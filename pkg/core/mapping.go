@@ -0,0 +1,216 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/blugelabs/bluge"
+	"github.com/prabhatsharma/zinc/pkg/analyzer"
+	"github.com/prabhatsharma/zinc/pkg/zutil"
+)
+
+// Field types that can be declared in an index mapping.
+const (
+	FieldTypeText    = "text"
+	FieldTypeKeyword = "keyword"
+	FieldTypeNumeric = "numeric"
+	FieldTypeDate    = "date"
+	FieldTypeBool    = "bool"
+	FieldTypeStored  = "stored" // stored only, not searchable
+
+	// fieldTypeTime is the type older versions inferred for time.Time values. It is kept as an alias of date.
+	fieldTypeTime = "time"
+)
+
+var fieldTypes = []string{FieldTypeText, FieldTypeKeyword, FieldTypeNumeric, FieldTypeDate, FieldTypeBool, FieldTypeStored}
+
+// Mappings is the explicit mapping of an index as accepted and returned by the _mapping API.
+type Mappings struct {
+	Properties map[string]Property `json:"properties"`
+}
+
+// Property declares how a single field is indexed.
+type Property struct {
+	Type string `json:"type"`
+	// Analyzer is the analyzer of a text field, used both at index and query time. Defaults to standard.
+	Analyzer string `json:"analyzer,omitempty"`
+}
+
+// analyzersDocSuffix is appended to the index name to get the id of the document
+// that stores the field analyzers in the _index_mapping system index.
+// Index names come from the URL path and cannot contain a slash, so the ids never collide.
+const analyzersDocSuffix = "/_analyzers"
+
+// GetMappings returns the current mapping of the index, declared and inferred fields alike.
+func (ind *Index) GetMappings() Mappings {
+	m := Mappings{Properties: make(map[string]Property, len(ind.CachedMapping))}
+	for field, typ := range ind.CachedMapping {
+		m.Properties[field] = Property{Type: typ, Analyzer: ind.CachedAnalyzers[field]}
+	}
+
+	return m
+}
+
+// SetAnalyzers saves the analyzers of the text fields of the index to _index_mapping index
+func (ind *Index) SetAnalyzers(analyzers map[string]string) error {
+	if err := setStoredFields(ind.Name+analyzersDocSuffix, analyzers); err != nil {
+		return err
+	}
+
+	ind.CachedAnalyzers = analyzers
+
+	return nil
+}
+
+// GetStoredAnalyzers returns the analyzers of the text fields of the index from _index_mapping system index
+func (ind *Index) GetStoredAnalyzers() (map[string]string, error) {
+	return getStoredFields(ind.Name + analyzersDocSuffix)
+}
+
+// DeleteStoredMapping deletes the mapping and the analyzers of the index from _index_mapping system index
+func (ind *Index) DeleteStoredMapping() error {
+	systemIndex := ZincSystemIndexList[SystemIndexMapping].Writer
+	if err := systemIndex.Delete(bluge.Identifier(ind.Name + analyzersDocSuffix)); err != nil {
+		return err
+	}
+
+	return systemIndex.Delete(bluge.Identifier(ind.Name))
+}
+
+// PutMappings declares the types of the given fields. Fields not mentioned keep their current type.
+// Documents indexed before a type change keep the old encoding, so declare the mapping before ingesting data.
+func (ind *Index) PutMappings(m Mappings) error {
+	for field, prop := range m.Properties {
+		if field == "" {
+			return fmt.Errorf("mapping contains an empty field name")
+		}
+		if !isValidFieldType(prop.Type) {
+			return fmt.Errorf("field [%s] has unknown type [%s], valid types are %v", field, prop.Type, fieldTypes)
+		}
+		if prop.Analyzer == "" {
+			continue
+		}
+		if prop.Type != FieldTypeText {
+			return fmt.Errorf("field [%s] of type [%s] cannot have an analyzer, only text fields are analyzed", field, prop.Type)
+		}
+		if _, err := analyzer.Get(prop.Analyzer); err != nil {
+			return fmt.Errorf("field [%s]: %v", field, err)
+		}
+	}
+
+	indexMapping := make(map[string]string, len(ind.CachedMapping)+len(m.Properties))
+	for field, typ := range ind.CachedMapping {
+		indexMapping[field] = typ
+	}
+	analyzers := make(map[string]string, len(ind.CachedAnalyzers))
+	for field, name := range ind.CachedAnalyzers {
+		analyzers[field] = name
+	}
+	for field, prop := range m.Properties {
+		indexMapping[field] = prop.Type
+		if prop.Analyzer != "" {
+			analyzers[field] = prop.Analyzer
+		} else {
+			delete(analyzers, field)
+		}
+	}
+
+	if err := ind.SetAnalyzers(analyzers); err != nil {
+		return err
+	}
+
+	return ind.SetMapping(indexMapping)
+}
+
+func isValidFieldType(typ string) bool {
+	return typ == fieldTypeTime || zutil.SliceContains(fieldTypes, typ)
+}
+
+// inferFieldType returns the field type to use for a value of a field that has no mapping yet.
+// An empty string is returned if the value cannot be indexed.
+func (ind *Index) inferFieldType(value interface{}) string {
+	if detectDate(value, ind.Settings.DateFormats) {
+		return FieldTypeDate
+	}
+
+	switch value.(type) {
+	case string:
+		return FieldTypeText
+	case float64:
+		return FieldTypeNumeric
+	case bool:
+		return FieldTypeBool
+	case time.Time:
+		return FieldTypeDate
+	}
+
+	return ""
+}
+
+// newBlugeField creates the bluge field for the value according to the mapped field type.
+// A nil field is returned for an unknown field type.
+// A *TypeConflictError is returned if the value cannot be indexed as the field type.
+func (ind *Index) newBlugeField(key, typ string, value interface{}) (bluge.Field, error) {
+	if ind.Settings.TypeConflictPolicy() != TypeConflictStrict {
+		value = coerce(typ, value)
+	}
+
+	switch typ {
+	case FieldTypeText:
+		if v, ok := value.(string); ok {
+			a, err := analyzer.Get(ind.CachedAnalyzers[key])
+			if err != nil {
+				return nil, err
+			}
+			return bluge.NewTextField(key, v).WithAnalyzer(a).SearchTermPositions(), nil
+		}
+	case FieldTypeKeyword:
+		switch v := value.(type) {
+		case string:
+			return bluge.NewKeywordField(key, v).Aggregatable().SearchTermPositions(), nil
+		case bool: // older versions mapped bool values as keyword
+			return bluge.NewKeywordField(key, strconv.FormatBool(v)).Aggregatable(), nil
+		}
+	case FieldTypeNumeric:
+		if v, ok := value.(float64); ok {
+			return bluge.NewNumericField(key, v), nil
+		}
+	case FieldTypeDate, fieldTypeTime:
+		if t, ok := ind.toTime(value); ok {
+			return bluge.NewDateTimeField(key, t).Sortable(), nil
+		}
+	case FieldTypeBool:
+		if v, ok := value.(bool); ok {
+			return bluge.NewKeywordField(key, strconv.FormatBool(v)).Aggregatable(), nil
+		}
+	case FieldTypeStored:
+		if v, ok := value.(string); ok {
+			return bluge.NewStoredOnlyField(key, []byte(v)), nil
+		}
+		v, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return bluge.NewStoredOnlyField(key, v), nil
+	default:
+		return nil, nil
+	}
+
+	return nil, &TypeConflictError{Field: key, Type: typ, Value: value}
+}
+
+// toTime converts the value of a date field to time. Numbers are taken as epoch seconds or milliseconds.
+func (ind *Index) toTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		return parseDate(v, ind.Settings.DateFormats)
+	case float64:
+		return epochToTime(v, epochFormat(ind.Settings.DateFormats)), true
+	}
+
+	return time.Time{}, false
+}
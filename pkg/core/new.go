@@ -36,9 +36,26 @@ func NewIndex(name string, storageType StorageType) (*Index, error) {
 
 	mapping, err := index.GetStoredMapping()
 	if err != nil {
+		writer.Close()
 		return nil, err
 	}
 
 	index.CachedMapping = mapping
+
+	analyzers, err := index.GetStoredAnalyzers()
+	if err != nil {
+		writer.Close()
+		return nil, err
+	}
+
+	index.CachedAnalyzers = analyzers
+
+	settings, err := index.GetStoredSettings()
+	if err != nil {
+		writer.Close()
+		return nil, err
+	}
+
+	index.Settings = settings
 	return index, nil
 }
@@ -0,0 +1,189 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/blugelabs/bluge"
+)
+
+// NoPipeline can be requested to skip the default pipeline of the index.
+const NoPipeline = "_none"
+
+// Pipeline is a named list of processors that reshape documents before they are indexed.
+type Pipeline struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	Processors  []*Processor `json:"processors"`
+}
+
+var (
+	pipelines     = make(map[string]*Pipeline)
+	pipelinesLock sync.RWMutex
+)
+
+// Run runs the processors on the document in order. It returns a nil document if a processor dropped it.
+func (p *Pipeline) Run(doc map[string]interface{}) (map[string]interface{}, error) {
+	for i, processor := range p.Processors {
+		dropped, err := processor.Run(doc)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline [%s] processor %d [%s]: %v", p.Name, i, processor.Type, err)
+		}
+		if dropped {
+			return nil, nil
+		}
+	}
+
+	return doc, nil
+}
+
+func (p *Pipeline) compile() error {
+	if p.Name == "" || p.Name == NoPipeline {
+		return fmt.Errorf("invalid pipeline name [%s]", p.Name)
+	}
+
+	for i, processor := range p.Processors {
+		if err := processor.compile(); err != nil {
+			return fmt.Errorf("processor %d [%s]: %v", i, processor.Type, err)
+		}
+	}
+
+	return nil
+}
+
+// GetPipeline returns the pipeline with the name.
+func GetPipeline(name string) (*Pipeline, bool) {
+	pipelinesLock.RLock()
+	defer pipelinesLock.RUnlock()
+
+	p, ok := pipelines[name]
+	return p, ok
+}
+
+// ListPipelines returns all pipelines sorted by name.
+func ListPipelines() []*Pipeline {
+	pipelinesLock.RLock()
+	defer pipelinesLock.RUnlock()
+
+	list := make([]*Pipeline, 0, len(pipelines))
+	for _, p := range pipelines {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	return list
+}
+
+// SetPipeline validates the pipeline and saves it to the _pipelines system index, replacing the pipeline with the same name.
+func SetPipeline(p *Pipeline) error {
+	if err := p.compile(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	bdoc := bluge.NewDocument(p.Name)
+	bdoc.AddField(bluge.NewStoredOnlyField("pipeline", data))
+	bdoc.AddField(bluge.NewCompositeFieldExcluding("_all", nil))
+
+	// update on the disk
+	systemIndex := ZincSystemIndexList[SystemIndexPipelines].Writer
+	if err := systemIndex.Update(bdoc.ID(), bdoc); err != nil {
+		log.Printf("error updating document: %v", err)
+		return err
+	}
+
+	// update in the cache
+	pipelinesLock.Lock()
+	pipelines[p.Name] = p
+	pipelinesLock.Unlock()
+
+	return nil
+}
+
+// DeletePipeline deletes the pipeline from the _pipelines system index.
+func DeletePipeline(name string) error {
+	if err := ZincSystemIndexList[SystemIndexPipelines].Writer.Delete(bluge.Identifier(name)); err != nil {
+		return err
+	}
+
+	pipelinesLock.Lock()
+	delete(pipelines, name)
+	pipelinesLock.Unlock()
+
+	return nil
+}
+
+// LoadPipelines loads all pipelines from the _pipelines system index.
+func LoadPipelines() error {
+	reader, err := ZincSystemIndexList[SystemIndexPipelines].Writer.Reader()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	dmi, err := reader.Search(context.Background(), bluge.NewAllMatches(bluge.NewMatchAllQuery()))
+	if err != nil {
+		return err
+	}
+
+	loaded := make(map[string]*Pipeline)
+	next, err := dmi.Next()
+	for err == nil && next != nil {
+		err = next.VisitStoredFields(func(field string, value []byte) bool {
+			if field != "pipeline" {
+				return true
+			}
+
+			var p Pipeline
+			if err := json.Unmarshal(value, &p); err != nil {
+				log.Printf("error decoding pipeline: %v", err)
+			} else if err := p.compile(); err != nil {
+				log.Printf("error loading pipeline %s: %v", p.Name, err)
+			} else {
+				loaded[p.Name] = &p
+			}
+			return false
+		})
+		if err != nil {
+			return err
+		}
+
+		next, err = dmi.Next()
+	}
+	if err != nil {
+		return err
+	}
+
+	pipelinesLock.Lock()
+	pipelines = loaded
+	pipelinesLock.Unlock()
+
+	log.Printf("%d pipelines loaded", len(loaded))
+	return nil
+}
+
+// ProcessDoc runs an ingest pipeline on the document: the named one, else the default pipeline of the index.
+// It returns a nil document if the pipeline dropped it.
+func (ind *Index) ProcessDoc(pipeline string, doc map[string]interface{}) (map[string]interface{}, error) {
+	if pipeline == "" {
+		pipeline = ind.Settings.DefaultPipeline
+	}
+	if pipeline == "" || pipeline == NoPipeline {
+		return doc, nil
+	}
+
+	p, ok := GetPipeline(pipeline)
+	if !ok {
+		return nil, fmt.Errorf("pipeline [%s] does not exist", pipeline)
+	}
+
+	return p.Run(doc)
+}
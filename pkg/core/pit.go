@@ -0,0 +1,199 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/blugelabs/bluge"
+	"github.com/google/uuid"
+	"github.com/prabhatsharma/zinc/pkg/zutil"
+)
+
+// maxKeepAlive is the longest a point in time is kept open without a search, the same as Elasticsearch.
+const maxKeepAlive = 24 * time.Hour
+
+// ErrTooManyPointsInTime is returned when a point in time would be opened beyond the maximum of ZINC_MAX_OPEN_PIT.
+var ErrTooManyPointsInTime = errors.New("too many points in time are open")
+
+// maxOpenPointsInTime returns the most points in time open at once, like search.max_open_pit_context of Elasticsearch.
+func maxOpenPointsInTime() int {
+	return zutil.GetEnvInt("ZINC_MAX_OPEN_PIT", 300)
+}
+
+// pointInTime is a reader of an index kept open, so that the searches with it see the documents of the index as
+// they were when it was opened, whatever the writes since. It is closed once not used for its keep alive.
+type pointInTime struct {
+	id     string
+	index  *Index
+	reader *bluge.Reader
+	timer  *time.Timer
+
+	// timerMu makes stopping and resetting the timer to extend the keep alive one step, expired tells that the timer
+	// fired or was stopped for good
+	timerMu sync.Mutex
+	expired bool
+
+	// mu is held for reading by the searches with the reader, and for writing to close it
+	mu     sync.RWMutex
+	closed bool
+}
+
+var (
+	pointsInTime     = make(map[string]*pointInTime)
+	pointsInTimeLock sync.Mutex
+)
+
+// OpenPointInTime opens a point in time on the index, kept open for keepAlive after its last search, and returns its id.
+func (ind *Index) OpenPointInTime(keepAlive string) (string, error) {
+	d, err := parseKeepAlive(keepAlive)
+	if err != nil {
+		return "", err
+	}
+
+	pointsInTimeLock.Lock()
+	defer pointsInTimeLock.Unlock()
+	if max := maxOpenPointsInTime(); len(pointsInTime) >= max {
+		return "", fmt.Errorf("%w, the maximum is %d, close the points in time that are not needed", ErrTooManyPointsInTime, max)
+	}
+
+	reader, err := ind.Writer.Reader()
+	if err != nil {
+		return "", err
+	}
+
+	pit := &pointInTime{id: uuid.New().String(), index: ind, reader: reader}
+	pointsInTime[pit.id] = pit
+	pit.timer = time.AfterFunc(d, pit.close)
+
+	return pit.id, nil
+}
+
+// ClosePointInTime closes the point in time with the id, and reports whether it was open.
+func ClosePointInTime(id string) bool {
+	pointsInTimeLock.Lock()
+	pit, ok := pointsInTime[id]
+	pointsInTimeLock.Unlock()
+	if !ok {
+		return false
+	}
+
+	pit.timer.Stop()
+	pit.close()
+	return true
+}
+
+// ClosePointsInTime closes the points in time opened on the index.
+func (ind *Index) ClosePointsInTime() {
+	var pits []*pointInTime
+	pointsInTimeLock.Lock()
+	for _, pit := range pointsInTime {
+		if pit.index == ind {
+			pits = append(pits, pit)
+		}
+	}
+	pointsInTimeLock.Unlock()
+
+	for _, pit := range pits {
+		pit.timer.Stop()
+		pit.close()
+	}
+}
+
+// acquirePointInTime returns the point in time of the index with the id, held for reading until released, and
+// keeps it open for keepAlive more if given.
+func (ind *Index) acquirePointInTime(id, keepAlive string) (*pointInTime, error) {
+	var d time.Duration
+	if keepAlive != "" {
+		var err error
+		if d, err = parseKeepAlive(keepAlive); err != nil {
+			return nil, err
+		}
+	}
+
+	pointsInTimeLock.Lock()
+	pit, ok := pointsInTime[id]
+	pointsInTimeLock.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("point in time [%s] does not exist, it may have expired", id)
+	}
+	if pit.index != ind {
+		return nil, fmt.Errorf("point in time [%s] was opened on index [%s], not [%s]", id, pit.index.Name, ind.Name)
+	}
+
+	pit.mu.RLock()
+	if pit.closed {
+		pit.mu.RUnlock()
+		return nil, fmt.Errorf("point in time [%s] does not exist, it may have expired", id)
+	}
+	if d > 0 && !pit.extend(d) {
+		pit.mu.RUnlock()
+		return nil, fmt.Errorf("point in time [%s] does not exist, it may have expired", id)
+	}
+	return pit, nil
+}
+
+// extend keeps the point in time open for d from now. It returns false if the timer already fired: the point in time
+// expired, and is closed once its searches release it.
+func (pit *pointInTime) extend(d time.Duration) bool {
+	pit.timerMu.Lock()
+	defer pit.timerMu.Unlock()
+	if pit.expired || !pit.timer.Stop() {
+		pit.expired = true
+		return false
+	}
+	pit.timer.Reset(d)
+	return true
+}
+
+// release ends the search with the point in time.
+func (pit *pointInTime) release() {
+	pit.mu.RUnlock()
+}
+
+// close closes the reader of the point in time once its searches are done. Closing it again does nothing.
+func (pit *pointInTime) close() {
+	pointsInTimeLock.Lock()
+	if pointsInTime[pit.id] == pit {
+		delete(pointsInTime, pit.id)
+	}
+	pointsInTimeLock.Unlock()
+
+	pit.mu.Lock()
+	defer pit.mu.Unlock()
+	if pit.closed {
+		return
+	}
+	pit.closed = true
+	if err := pit.reader.Close(); err != nil {
+		log.Printf("error closing point in time [%s]: %v", pit.id, err)
+	}
+}
+
+var keepAliveRegexp = regexp.MustCompile(`^(\d+)(ms|s|m|h|d)$`)
+
+// parseKeepAlive parses a keep alive like 30s, 5m or 1d, up to maxKeepAlive.
+func parseKeepAlive(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, fmt.Errorf("keep_alive is required")
+	}
+	m := keepAliveRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("invalid keep_alive [%s], use a duration like 30s, 5m or 1h", s)
+	}
+	n, _ := strconv.Atoi(m[1])
+	unit := map[string]time.Duration{"ms": time.Millisecond, "s": time.Second, "m": time.Minute, "h": time.Hour, "d": 24 * time.Hour}[m[2]]
+
+	d := time.Duration(n) * unit
+	if d <= 0 {
+		return 0, fmt.Errorf("keep_alive must be greater than 0")
+	}
+	if d > maxKeepAlive {
+		return 0, fmt.Errorf("keep_alive [%s] is longer than the maximum of %v", s, maxKeepAlive)
+	}
+	return d, nil
+}
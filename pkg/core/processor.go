@@ -0,0 +1,459 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prabhatsharma/zinc/pkg/zutil"
+)
+
+// Processor types of ingest pipelines.
+const (
+	ProcessorSet       = "set"       // sets field to value, {{other.field}} in a string value is replaced
+	ProcessorRemove    = "remove"    // removes field
+	ProcessorRename    = "rename"    // renames field to target_field
+	ProcessorLowercase = "lowercase" // lowercases the string(s) of field
+	ProcessorUppercase = "uppercase" // uppercases the string(s) of field
+	ProcessorSplit     = "split"     // splits the string of field into an array by the separator regular expression
+	ProcessorConvert   = "convert"   // converts field to integer, float, string, boolean or auto
+	ProcessorDate      = "date"      // parses field with the formats and sets target_field (@timestamp by default) to it
+	ProcessorGrok      = "grok"      // extracts fields from field with the first matching grok pattern
+	ProcessorRegex     = "regex"     // extracts the named groups of the first matching regular expression as fields
+	ProcessorJSON      = "json"      // parses the JSON string of field
+	ProcessorDrop      = "drop"      // drops the document, usually combined with if
+)
+
+var processorTypes = []string{
+	ProcessorSet, ProcessorRemove, ProcessorRename, ProcessorLowercase, ProcessorUppercase, ProcessorSplit,
+	ProcessorConvert, ProcessorDate, ProcessorGrok, ProcessorRegex, ProcessorJSON, ProcessorDrop,
+}
+
+// Processor is a single step of an ingest pipeline. Type selects what it does, the other fields are its options.
+// Fields are addressed by their dot separated path, e.g. user.name.
+type Processor struct {
+	Type        string      `json:"type"`
+	Field       string      `json:"field,omitempty"`
+	TargetField string      `json:"target_field,omitempty"` // defaults to field
+	Value       interface{} `json:"value,omitempty"`        // set
+	Separator   string      `json:"separator,omitempty"`    // split
+	To          string      `json:"to,omitempty"`           // convert
+	Formats     []string    `json:"formats,omitempty"`      // date, see IndexSettings.DateFormats
+	Timezone    string      `json:"timezone,omitempty"`     // date, for formats without a zone. Defaults to UTC
+	Patterns    []string    `json:"patterns,omitempty"`     // grok and regex
+	// PatternDefinitions are custom grok patterns that can be used in the patterns besides the built-in ones.
+	PatternDefinitions map[string]string `json:"pattern_definitions,omitempty"`
+	AddToRoot          bool              `json:"add_to_root,omitempty"` // json, merges the parsed object into the document
+
+	// If makes the processor run only for the documents that match the condition.
+	If *Condition `json:"if,omitempty"`
+	// IgnoreMissing skips the processor for documents without the field instead of failing.
+	IgnoreMissing bool `json:"ignore_missing,omitempty"`
+	// IgnoreFailure skips the processor when it fails instead of rejecting the document.
+	IgnoreFailure bool `json:"ignore_failure,omitempty"`
+
+	separator *regexp.Regexp
+	location  *time.Location
+	patterns  []*extractPattern
+}
+
+// Condition matches documents by the value of a field. All the checks that are set must hold.
+type Condition struct {
+	Field   string        `json:"field"`
+	Exists  *bool         `json:"exists,omitempty"`
+	Equals  interface{}   `json:"equals,omitempty"`
+	In      []interface{} `json:"in,omitempty"`
+	Matches string        `json:"matches,omitempty"` // regular expression
+	Not     bool          `json:"not,omitempty"`     // negates the result
+
+	matches *regexp.Regexp
+}
+
+// ConvertTypes are the types values can be converted to, by the convert processor and the import API.
+var ConvertTypes = []string{"integer", "float", "string", "boolean", "auto"}
+
+var templateRegexp = regexp.MustCompile(`\{\{\s*([^{}\s]+)\s*\}\}`)
+
+func (p *Processor) compile() (err error) {
+	if !zutil.SliceContains(processorTypes, p.Type) {
+		return fmt.Errorf("unknown processor type [%s], valid types are %v", p.Type, processorTypes)
+	}
+	if p.Field == "" && p.Type != ProcessorDrop {
+		return fmt.Errorf("field is required")
+	}
+	if p.If != nil {
+		if err := p.If.compile(); err != nil {
+			return err
+		}
+	}
+
+	switch p.Type {
+	case ProcessorRename:
+		if p.TargetField == "" {
+			return fmt.Errorf("target_field is required")
+		}
+	case ProcessorSplit:
+		if p.Separator == "" {
+			return fmt.Errorf("separator is required")
+		}
+		p.separator, err = regexp.Compile(p.Separator)
+	case ProcessorConvert:
+		if !zutil.SliceContains(ConvertTypes, p.To) {
+			return fmt.Errorf("unknown convert type [%s]", p.To)
+		}
+	case ProcessorDate:
+		if len(p.Formats) == 0 {
+			return fmt.Errorf("formats are required")
+		}
+		p.location, err = time.LoadLocation(p.Timezone)
+	case ProcessorGrok, ProcessorRegex:
+		if len(p.Patterns) == 0 {
+			return fmt.Errorf("patterns are required")
+		}
+		p.patterns = make([]*extractPattern, len(p.Patterns))
+		for i, pattern := range p.Patterns {
+			if p.Type == ProcessorGrok {
+				p.patterns[i], err = compileGrok(pattern, p.PatternDefinitions)
+			} else {
+				p.patterns[i], err = compileRegex(pattern)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return err
+}
+
+// Run runs the processor on the document, changing it in place. It returns true if the document is dropped.
+func (p *Processor) Run(doc map[string]interface{}) (bool, error) {
+	if p.If != nil && !p.If.Match(doc) {
+		return false, nil
+	}
+
+	switch p.Type {
+	case ProcessorDrop:
+		return true, nil
+	case ProcessorSet:
+		setPath(doc, p.Field, p.setValue(doc))
+		return false, nil
+	}
+
+	value, ok := getPath(doc, p.Field)
+	if !ok {
+		if p.IgnoreMissing {
+			return false, nil
+		}
+		return p.fail(fmt.Errorf("field [%s] does not exist", p.Field))
+	}
+
+	if err := p.apply(doc, value); err != nil {
+		return p.fail(err)
+	}
+
+	return false, nil
+}
+
+func (p *Processor) fail(err error) (bool, error) {
+	if p.IgnoreFailure {
+		return false, nil
+	}
+
+	return false, err
+}
+
+func (p *Processor) target() string {
+	if p.TargetField != "" {
+		return p.TargetField
+	}
+
+	return p.Field
+}
+
+func (p *Processor) setValue(doc map[string]interface{}) interface{} {
+	s, ok := p.Value.(string)
+	if !ok {
+		return p.Value
+	}
+
+	return ExpandTemplate(s, doc)
+}
+
+// ExpandTemplate replaces each {{field}} in the template with the value of the field of the document.
+// Missing fields are replaced with an empty string.
+func ExpandTemplate(template string, doc map[string]interface{}) string {
+	return templateRegexp.ReplaceAllStringFunc(template, func(m string) string {
+		if v, ok := getPath(doc, templateRegexp.FindStringSubmatch(m)[1]); ok {
+			return fmt.Sprint(v)
+		}
+		return ""
+	})
+}
+
+func (p *Processor) apply(doc map[string]interface{}, value interface{}) error {
+	switch p.Type {
+	case ProcessorRemove:
+		deletePath(doc, p.Field)
+	case ProcessorRename:
+		deletePath(doc, p.Field)
+		setPath(doc, p.TargetField, value)
+	case ProcessorLowercase, ProcessorUppercase:
+		fn := strings.ToLower
+		if p.Type == ProcessorUppercase {
+			fn = strings.ToUpper
+		}
+		v, err := mapValues(value, func(v interface{}) (interface{}, error) {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("field [%s] is not a string", p.Field)
+			}
+			return fn(s), nil
+		})
+		if err != nil {
+			return err
+		}
+		setPath(doc, p.target(), v)
+	case ProcessorSplit:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("field [%s] is not a string", p.Field)
+		}
+		parts := p.separator.Split(s, -1)
+		values := make([]interface{}, len(parts))
+		for i, part := range parts {
+			values[i] = part
+		}
+		setPath(doc, p.target(), values)
+	case ProcessorConvert:
+		v, err := mapValues(value, func(v interface{}) (interface{}, error) { return ConvertValue(v, p.To) })
+		if err != nil {
+			return fmt.Errorf("field [%s]: %v", p.Field, err)
+		}
+		setPath(doc, p.target(), v)
+	case ProcessorDate:
+		t, err := p.parseDate(value)
+		if err != nil {
+			return err
+		}
+		target := p.TargetField
+		if target == "" {
+			target = TimestampField
+		}
+		setPath(doc, target, t.Format(time.RFC3339Nano))
+	case ProcessorGrok, ProcessorRegex:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("field [%s] is not a string", p.Field)
+		}
+		for _, pattern := range p.patterns {
+			if fields, ok := pattern.extract(s); ok {
+				for k, v := range fields {
+					setPath(doc, k, v)
+				}
+				return nil
+			}
+		}
+		return fmt.Errorf("field [%s] does not match any of the patterns", p.Field)
+	case ProcessorJSON:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("field [%s] is not a string", p.Field)
+		}
+		var v interface{}
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			return fmt.Errorf("field [%s] is not valid JSON: %v", p.Field, err)
+		}
+		if !p.AddToRoot {
+			setPath(doc, p.target(), v)
+			return nil
+		}
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("field [%s] is not a JSON object, it cannot be added to the root", p.Field)
+		}
+		for k, v := range m {
+			doc[k] = v
+		}
+	}
+
+	return nil
+}
+
+func (p *Processor) parseDate(value interface{}) (time.Time, error) {
+	for _, format := range p.Formats {
+		switch v := value.(type) {
+		case string:
+			if t, ok := parseDateFormatIn(v, format, p.location); ok {
+				return t, nil
+			}
+		case float64:
+			if format == DateFormatEpochSecond || format == DateFormatEpochMillis {
+				return epochToTime(v, format), nil
+			}
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("field [%s] value %v does not match any of the formats %v", p.Field, value, p.Formats)
+}
+
+// mapValues applies fn to the value, or to each element if the value is an array.
+func mapValues(value interface{}, fn func(interface{}) (interface{}, error)) (interface{}, error) {
+	values, ok := value.([]interface{})
+	if !ok {
+		return fn(value)
+	}
+
+	mapped := make([]interface{}, len(values))
+	for i, v := range values {
+		var err error
+		if mapped[i], err = fn(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return mapped, nil
+}
+
+// ConvertValue converts the value to one of the ConvertTypes. Integers are kept as float64 like all JSON numbers,
+// values that are not whole numbers up to 2^53 are not integers.
+func ConvertValue(value interface{}, to string) (interface{}, error) {
+	s := strings.TrimSpace(fmt.Sprint(value))
+
+	switch to {
+	case "integer":
+		if f, ok := parseNumber(s); ok && f == math.Trunc(f) && math.Abs(f) <= maxExactInteger {
+			return f, nil
+		}
+	case "float":
+		if f, ok := parseNumber(s); ok {
+			return f, nil
+		}
+	case "string":
+		return s, nil
+	case "boolean":
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b, nil
+		}
+	case "auto":
+		if f, ok := parseNumber(s); ok {
+			return f, nil
+		}
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b, nil
+		}
+		return value, nil
+	}
+
+	return nil, fmt.Errorf("cannot convert %v to %s", value, to)
+}
+
+// maxExactInteger is the largest integer a float64 holds exactly, 2^53.
+const maxExactInteger = 1 << 53
+
+// parseNumber parses a finite number. NaN and infinities are not numbers of JSON documents.
+func parseNumber(s string) (float64, bool) {
+	f, err := strconv.ParseFloat(s, 64)
+	return f, err == nil && !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+func (c *Condition) compile() (err error) {
+	if c.Field == "" {
+		return fmt.Errorf("if: field is required")
+	}
+	if c.Matches != "" {
+		c.matches, err = regexp.Compile(c.Matches)
+	}
+
+	return err
+}
+
+// Match reports whether the document matches the condition.
+func (c *Condition) Match(doc map[string]interface{}) bool {
+	value, exists := getPath(doc, c.Field)
+
+	match := true
+	if c.Exists != nil {
+		match = exists == *c.Exists
+	}
+	if match && c.Equals != nil {
+		match = exists && fmt.Sprint(value) == fmt.Sprint(c.Equals)
+	}
+	if match && len(c.In) > 0 {
+		match = exists && func() bool {
+			for _, v := range c.In {
+				if fmt.Sprint(value) == fmt.Sprint(v) {
+					return true
+				}
+			}
+			return false
+		}()
+	}
+	if match && c.matches != nil {
+		match = exists && c.matches.MatchString(fmt.Sprint(value))
+	}
+
+	return match != c.Not
+}
+
+// getPath returns the value of the dot separated path in the document.
+// A key containing dots that exists as is in the document takes precedence.
+func getPath(doc map[string]interface{}, path string) (interface{}, bool) {
+	if v, ok := doc[path]; ok {
+		return v, true
+	}
+
+	parent, key, ok := parentOf(doc, path, false)
+	if !ok {
+		return nil, false
+	}
+
+	v, ok := parent[key]
+	return v, ok
+}
+
+// setPath sets the value of the dot separated path in the document, creating the intermediate objects.
+func setPath(doc map[string]interface{}, path string, value interface{}) {
+	if _, ok := doc[path]; ok {
+		doc[path] = value
+		return
+	}
+
+	parent, key, _ := parentOf(doc, path, true)
+	parent[key] = value
+}
+
+// deletePath deletes the dot separated path from the document.
+func deletePath(doc map[string]interface{}, path string) {
+	if _, ok := doc[path]; ok {
+		delete(doc, path)
+		return
+	}
+
+	if parent, key, ok := parentOf(doc, path, false); ok {
+		delete(parent, key)
+	}
+}
+
+// parentOf returns the object holding the last key of the path, optionally creating the missing objects.
+func parentOf(doc map[string]interface{}, path string, create bool) (map[string]interface{}, string, bool) {
+	keys := strings.Split(path, ".")
+	parent := doc
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := parent[key].(map[string]interface{})
+		if !ok {
+			if !create {
+				return nil, "", false
+			}
+			child = make(map[string]interface{})
+			parent[key] = child
+		}
+		parent = child
+	}
+
+	return parent, keys[len(keys)-1], true
+}
@@ -3,23 +3,32 @@ package core
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"time"
 
 	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
 	v1 "github.com/prabhatsharma/zinc/pkg/meta/v1"
 	"github.com/prabhatsharma/zinc/pkg/uquery"
 )
 
 func (ind *Index) Search(q v1.ZincQuery) (v1.SearchResponse, error) {
-	var Hits []v1.Hit
-
 	var searchRequest bluge.SearchRequest
 
 	if q.MaxResults == 0 {
 		q.MaxResults = 20
 	}
 
+	// Analyze the query terms the same way the fields were analyzed at index time
+	q.FieldAnalyzers = ind.CachedAnalyzers
+	q.FieldTypes = ind.CachedMapping
+
+	// Hits with sort values have sort values of their own, to page from any of them with search_after
+	if len(q.SortFields) > 0 || len(q.SearchAfter) > 0 || q.PIT != nil {
+		q.SortFields = uquery.PagingSortFields(q.SortFields)
+	}
+
 	var err error
 
 	switch q.SearchType {
@@ -45,63 +54,145 @@ func (ind *Index) Search(q v1.ZincQuery) (v1.SearchResponse, error) {
 		searchRequest, err = uquery.PrefixQuery(q)
 	case "querystring":
 		searchRequest, err = uquery.QueryStringQuery(q)
+	case "bool":
+		searchRequest, err = uquery.BoolQuery(q)
+	}
+
+	if err == nil && searchRequest == nil {
+		err = fmt.Errorf("unknown search_type [%s]", q.SearchType)
+	}
+	if err == nil && len(q.Aggs) > 0 {
+		err = uquery.AddAggregations(searchRequest, q.Aggs, q.FieldTypes)
+	}
+	if err == nil {
+		err = uquery.SearchAfter(searchRequest, q)
+	}
+	var opts *hitOptions
+	if err == nil {
+		opts, err = ind.newHitOptions(q)
+	}
+	if err != nil {
+		return v1.SearchResponse{Error: err.Error()}, err
+	}
+
+	reader, closeReader, err := ind.searchReader(q.PIT)
+	if err != nil {
+		return v1.SearchResponse{Error: err.Error()}, err
+	}
+	defer closeReader()
+
+	resp, aggs, err := ind.search(reader, searchRequest, opts)
+	if err != nil {
+		return resp, err
 	}
+	if q.PIT != nil {
+		resp.PitID = q.PIT.ID
+	}
+	resp.Aggregations = uquery.AggregationResults(q.Aggs, q.FieldTypes, aggs)
+
+	return resp, nil
+}
+
+// SearchDSL searches the index with a request of the Elasticsearch query DSL.
+func (ind *Index) SearchDSL(q v1.DSLQuery) (v1.SearchResponse, error) {
+	q.FieldTypes = ind.CachedMapping
+	q.FieldAnalyzers = ind.CachedAnalyzers
 
+	searchRequest, err := uquery.DSLSearch(q)
 	if err != nil {
 		return v1.SearchResponse{Error: err.Error()}, err
 	}
 
-	// sample time range aggregation start
-	// timestampAggregation := aggregations.DateRanges(search.Field("@timestamp"))
-	// daterange1 := aggregations.NewDateRange(time.Now().Add(-time.Hour*24*30), time.Now())
-	// timestampAggregation.AddRange(daterange1)
-	// searchRequest.AddAggregation("@timestamp", timestampAggregation)
-	// sample time range aggregation end
+	reader, err := ind.Writer.Reader()
+	if err != nil {
+		log.Printf("error accessing reader: %v", err)
+		return v1.SearchResponse{Error: err.Error()}, err
+	}
+	defer reader.Close()
+
+	resp, _, err := ind.search(reader, searchRequest, nil)
+	return resp, err
+}
+
+// Explain tells whether the document with the id matches the query string, and how its score is computed.
+// found is false if the index has no document with the id.
+func (ind *Index) Explain(docID, q string) (resp v1.ExplainResponse, found bool, err error) {
+	resp = v1.ExplainResponse{Index: ind.Name, Type: ind.Name, ID: docID}
+
+	query, err := uquery.ExplainQuery(q, ind.CachedAnalyzers)
+	if err != nil {
+		return resp, false, err
+	}
+
+	reader, err := ind.Writer.Reader()
+	if err != nil {
+		return resp, false, err
+	}
+	defer reader.Close()
+
+	explain := func(query bluge.Query) (*search.DocumentMatch, error) {
+		dmi, err := reader.Search(context.Background(), uquery.ExplainRequest(docID, query))
+		if err != nil {
+			return nil, err
+		}
+		return dmi.Next()
+	}
+
+	next, err := explain(query)
+	if err != nil {
+		return resp, false, err
+	}
+	if next != nil {
+		resp.Matched = true
+		resp.Explanation = next.Explanation
+		return resp, true, nil
+	}
+
+	// no match, tell a document that does not match from a missing one, and why it does not match
+	doc, err := ind.readDoc(reader, docID)
+	if err != nil || !doc.Found {
+		return resp, false, err
+	}
+	resp.Explanation, err = uquery.ExplainMiss(query, explain)
+	return resp, true, err
+}
 
-	writer := ind.Writer
+// searchReader returns the reader of the point in time if given, else of the latest documents of the index, and
+// the function that ends the search with it.
+func (ind *Index) searchReader(pit *v1.PointInTime) (*bluge.Reader, func(), error) {
+	if pit != nil {
+		p, err := ind.acquirePointInTime(pit.ID, pit.KeepAlive)
+		if err != nil {
+			return nil, nil, err
+		}
+		return p.reader, p.release, nil
+	}
 
-	reader, err := writer.Reader()
+	reader, err := ind.Writer.Reader()
 	if err != nil {
 		log.Printf("error accessing reader: %v", err)
+		return nil, nil, err
 	}
+	return reader, func() { reader.Close() }, nil
+}
+
+// search runs the search request with the reader and returns the matching documents as selected by the hit options,
+// and the results of the aggregations of the request.
+func (ind *Index) search(reader *bluge.Reader, searchRequest bluge.SearchRequest, opts *hitOptions) (v1.SearchResponse, *search.Bucket, error) {
+	var Hits []v1.Hit
 
 	dmi, err := reader.Search(context.Background(), searchRequest)
 	if err != nil {
 		log.Printf("error executing search: %v", err)
+		return v1.SearchResponse{Error: err.Error()}, nil, err
 	}
 
-	// highlighter := highlight.NewANSIHighlighter()
-
 	// iterationStartTime := time.Now()
 	next, err := dmi.Next()
 	for err == nil && next != nil {
-		var result map[string]interface{}
-		var id string
-		var timestamp time.Time
-		err = next.VisitStoredFields(func(field string, value []byte) bool {
-			if field == "_source" {
-				json.Unmarshal(value, &result)
-				return true
-			} else if field == "_id" {
-				id = string(value)
-				return true
-			} else if field == "@timestamp" {
-				timestamp, _ = bluge.DecodeDateTime(value)
-				return true
-			}
-			return true
-		})
-		if err != nil {
-			log.Printf("error accessing stored fields: %v", err)
-		}
-
-		hit := v1.Hit{
-			Index:     ind.Name,
-			Type:      ind.Name,
-			ID:        id,
-			Score:     next.Score,
-			Timestamp: timestamp,
-			Source:    result,
+		hit, hitErr := ind.newHit(next, opts)
+		if hitErr != nil {
+			log.Printf("error accessing stored fields: %v", hitErr)
 		}
 
 		next, err = dmi.Next()
@@ -118,7 +209,6 @@ func (ind *Index) Search(q v1.ZincQuery) (v1.SearchResponse, error) {
 		// Took: int(time.Since(searchStart).Milliseconds()),
 		Took:     int(dmi.Aggregations().Duration().Milliseconds()),
 		MaxScore: dmi.Aggregations().Metric("max_score"),
-		// Buckets:  dmi.Aggregations().Buckets("@timestamp"),
 		Hits: v1.Hits{
 			Total: v1.Total{
 				Value: int(dmi.Aggregations().Count()),
@@ -127,7 +217,60 @@ func (ind *Index) Search(q v1.ZincQuery) (v1.SearchResponse, error) {
 		},
 	}
 
-	reader.Close()
+	return resp, dmi.Aggregations(), nil
+}
 
-	return resp, nil
+// newHit builds the hit from the stored fields of the document match, with the full _source if opts is nil
+func (ind *Index) newHit(next *search.DocumentMatch, opts *hitOptions) (v1.Hit, error) {
+	var result map[string]interface{}
+	var id string
+	var timestamp time.Time
+	var version, seqNo float64
+	stored := make(map[string][]interface{})
+	err := next.VisitStoredFields(func(field string, value []byte) bool {
+		if field == "_source" {
+			if opts.decodeSource() {
+				json.Unmarshal(value, &result)
+			}
+			return true
+		} else if field == "_id" {
+			id = string(value)
+			return true
+		} else if field == TimestampField {
+			timestamp, _ = bluge.DecodeDateTime(value)
+			return true
+		} else if field == VersionField {
+			version, _ = bluge.DecodeNumericFloat64(value)
+			return true
+		} else if field == SeqNoField {
+			seqNo, _ = bluge.DecodeNumericFloat64(value)
+			return true
+		} else if opts != nil && opts.stored[field] {
+			stored[field] = append(stored[field], string(value))
+			return true
+		}
+		return true
+	})
+
+	if version == 0 { // written before documents were versioned
+		version = 1
+	}
+
+	hit := v1.Hit{
+		Index:       ind.Name,
+		Type:        ind.Name,
+		ID:          id,
+		Score:       next.Score,
+		Version:     int64(version),
+		SeqNo:       int64(seqNo),
+		Timestamp:   timestamp,
+		Source:      result,
+		Explanation: next.Explanation,
+	}
+
+	if err == nil && opts != nil {
+		err = opts.apply(&hit, next, result, stored)
+	}
+
+	return hit, err
 }
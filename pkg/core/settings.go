@@ -0,0 +1,130 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/blugelabs/bluge"
+	"github.com/prabhatsharma/zinc/pkg/zutil"
+)
+
+// IndexSettings control how the documents of an index are indexed.
+type IndexSettings struct {
+	// DateFormats are tried before the default formats to detect and parse date strings.
+	// A format is either a Go time layout or one of the names in dateFormatNames.
+	DateFormats []string `json:"date_formats,omitempty"`
+	// TypeConflict is the policy for values that do not match the type of their field:
+	// reject (default), ignore or strict.
+	TypeConflict string `json:"type_conflict,omitempty"`
+	// TimestampField is the document field holding the event time that is indexed as @timestamp. Defaults to @timestamp.
+	// The ingest time is used for documents without the field.
+	TimestampField string `json:"timestamp_field,omitempty"`
+	// TimestampFormat is the date format of the timestamp field, see DateFormats.
+	// Without it the timestamp is parsed like any other date field.
+	TimestampFormat string `json:"timestamp_format,omitempty"`
+	// DefaultPipeline is the ingest pipeline run on the documents that do not request one.
+	DefaultPipeline string `json:"default_pipeline,omitempty"`
+}
+
+// EventTimeField returns the document field holding the event time.
+func (s IndexSettings) EventTimeField() string {
+	if s.TimestampField == "" {
+		return TimestampField
+	}
+
+	return s.TimestampField
+}
+
+// TypeConflictPolicy returns the type conflict policy, reject if none is set.
+func (s IndexSettings) TypeConflictPolicy() string {
+	if s.TypeConflict == "" {
+		return TypeConflictReject
+	}
+
+	return s.TypeConflict
+}
+
+// Validate checks the settings for invalid values.
+func (s IndexSettings) Validate() error {
+	if s.TypeConflict != "" && !zutil.SliceContains(typeConflictPolicies, s.TypeConflict) {
+		return fmt.Errorf("unknown type_conflict [%s], valid policies are %v", s.TypeConflict, typeConflictPolicies)
+	}
+	for _, format := range s.DateFormats {
+		if !validDateFormat(format) {
+			return fmt.Errorf("invalid date format [%s], use a Go time layout or one of the named formats", format)
+		}
+	}
+	if s.TimestampFormat != "" && !validDateFormat(s.TimestampFormat) {
+		return fmt.Errorf("invalid timestamp_format [%s], use a Go time layout or one of the named formats", s.TimestampFormat)
+	}
+
+	return nil
+}
+
+// SetSettings saves the settings of the index to _index_settings index
+func (ind *Index) SetSettings(settings IndexSettings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+
+	bdoc := bluge.NewDocument(ind.Name)
+	bdoc.AddField(bluge.NewStoredOnlyField("settings", data))
+	bdoc.AddField(bluge.NewCompositeFieldExcluding("_all", nil))
+
+	// update on the disk
+	systemIndex := ZincSystemIndexList[SystemIndexSettings].Writer
+	if err := systemIndex.Update(bdoc.ID(), bdoc); err != nil {
+		log.Printf("error updating document: %v", err)
+		return err
+	}
+
+	// update in the cache
+	ind.Settings = settings
+
+	return nil
+}
+
+// DeleteStoredSettings deletes the settings of the index from _index_settings system index
+func (ind *Index) DeleteStoredSettings() error {
+	return ZincSystemIndexList[SystemIndexSettings].Writer.Delete(bluge.Identifier(ind.Name))
+}
+
+// GetStoredSettings returns the settings of the index from _index_settings system index
+func (ind *Index) GetStoredSettings() (IndexSettings, error) {
+	var settings IndexSettings
+
+	config := bluge.DefaultConfig(zutil.GetDataDir() + "/" + SystemIndexSettings)
+	reader, err := bluge.OpenReader(config)
+	if err != nil {
+		return settings, nil // probably no system index available
+	}
+	defer reader.Close()
+
+	query := bluge.NewTermQuery(ind.Name).SetField("_id")
+	dmi, err := reader.Search(context.Background(), bluge.NewTopNSearch(1, query))
+	if err != nil {
+		return settings, err
+	}
+
+	next, err := dmi.Next()
+	if err != nil || next == nil {
+		return settings, err
+	}
+
+	err = next.VisitStoredFields(func(field string, value []byte) bool {
+		if field == "settings" {
+			err = json.Unmarshal(value, &settings)
+			return false
+		}
+		return true
+	})
+
+	return settings, err
+}
@@ -1,6 +1,9 @@
 package core
 
 import (
+	"log"
+	"sync"
+
 	"github.com/blugelabs/bluge"
 )
 
@@ -38,6 +41,9 @@ func GetIndex(indexName string) (*Index, error) {
 func Init() {
 	ZincIndexList, _ = LoadZincIndexesFromDisk()
 	ZincSystemIndexList, _ = LoadZincSystemIndexes()
+	if err := LoadPipelines(); err != nil {
+		log.Printf("Error loading pipelines: %v", err)
+	}
 
 	s3List, _ := LoadZincIndexesFromS3()
 	for k, v := range s3List {
@@ -46,9 +52,17 @@ func Init() {
 }
 
 type Index struct {
-	Name          string                `json:"name"`
-	Writer        *bluge.Writer         `json:"-"`
-	CachedMapping map[string]string     `json:"mapping"`
-	IndexType     string                `json:"index_type"` // "system" or "user"
-	StorageType   `json:"storage_type"` // disk, memory, s3
+	Name            string                `json:"name"`
+	Writer          *bluge.Writer         `json:"-"`
+	CachedMapping   map[string]string     `json:"mapping"`
+	CachedAnalyzers map[string]string     `json:"analyzers"`
+	Settings        IndexSettings         `json:"settings"`
+	IndexType       string                `json:"index_type"` // "system" or "user"
+	StorageType     `json:"storage_type"` // disk, memory, s3
+
+	// docLock serializes the read-modify-write cycles on the documents of the index
+	docLock sync.Mutex
+	// seqNo is the sequence number of the last write, loaded from the index on the first write. Guarded by docLock.
+	seqNo       int64
+	seqNoLoaded bool
 }
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+)
+
+// Stored fields that carry the version of a document.
+const (
+	// VersionField counts the writes of the document, starting at 1.
+	VersionField = "_version"
+	// SeqNoField orders the writes of the index: every write gets a sequence number higher than all before it.
+	SeqNoField = "_seq_no"
+)
+
+// Version types of a version precondition.
+const (
+	VersionTypeInternal    = "internal"     // the current version must equal the given version
+	VersionTypeExternal    = "external"     // the given version must be higher than the current version and becomes the new version
+	VersionTypeExternalGTE = "external_gte" // like external, but the given version may equal the current version
+)
+
+// Precondition is the optimistic concurrency control check of a write. The zero Precondition always passes.
+type Precondition struct {
+	// IfSeqNo requires the document to exist with the sequence number.
+	IfSeqNo *int64
+	// Version is checked against the current version of the document according to VersionType.
+	Version     *int64
+	VersionType string
+}
+
+// Validate returns an error if the precondition cannot be checked.
+func (p Precondition) Validate() error {
+	switch p.VersionType {
+	case "", VersionTypeInternal, VersionTypeExternal, VersionTypeExternalGTE:
+	default:
+		return fmt.Errorf("invalid version_type [%s], valid types are %v", p.VersionType,
+			[]string{VersionTypeInternal, VersionTypeExternal, VersionTypeExternalGTE})
+	}
+	if p.Version != nil && *p.Version < 0 {
+		return fmt.Errorf("invalid version [%d], versions cannot be negative", *p.Version)
+	}
+	if p.IfSeqNo != nil && p.Version != nil {
+		return fmt.Errorf("if_seq_no and version cannot be used together")
+	}
+
+	return nil
+}
+
+// VersionConflictError is returned when a write does not meet its precondition.
+type VersionConflictError struct {
+	ID     string
+	Reason string
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("[%s]: version conflict, %s", e.ID, e.Reason)
+}
+
+// docVersion is the version of a stored document.
+type docVersion struct {
+	Found   bool
+	Version int64
+	SeqNo   int64
+}
+
+// check returns the version the document gets with the write, or a *VersionConflictError if the precondition fails.
+func (p Precondition) check(docID string, current docVersion) (int64, error) {
+	switch {
+	case p.IfSeqNo != nil:
+		if !current.Found {
+			return 0, &VersionConflictError{ID: docID, Reason: fmt.Sprintf("required seq_no [%d], but no document was found", *p.IfSeqNo)}
+		}
+		if current.SeqNo != *p.IfSeqNo {
+			return 0, &VersionConflictError{ID: docID, Reason: fmt.Sprintf("required seq_no [%d], current document has seq_no [%d]", *p.IfSeqNo, current.SeqNo)}
+		}
+	case p.Version != nil && (p.VersionType == VersionTypeExternal || p.VersionType == VersionTypeExternalGTE):
+		if current.Found && (*p.Version < current.Version || *p.Version == current.Version && p.VersionType == VersionTypeExternal) {
+			return 0, &VersionConflictError{ID: docID, Reason: fmt.Sprintf("current version [%d] is higher than or equal to the one provided [%d]", current.Version, *p.Version)}
+		}
+		return *p.Version, nil
+	case p.Version != nil:
+		if !current.Found {
+			return 0, &VersionConflictError{ID: docID, Reason: fmt.Sprintf("required version [%d], but no document was found", *p.Version)}
+		}
+		if current.Version != *p.Version {
+			return 0, &VersionConflictError{ID: docID, Reason: fmt.Sprintf("current version [%d] is different than the one provided [%d]", current.Version, *p.Version)}
+		}
+	}
+
+	if !current.Found {
+		return 1, nil
+	}
+	return current.Version + 1, nil
+}
+
+// maxSeqNo returns the highest sequence number of the documents of the index, or 0 if no document has one.
+func maxSeqNo(reader *bluge.Reader) (int64, error) {
+	req := bluge.NewTopNSearch(1, bluge.NewMatchAllQuery())
+	req.AddAggregation("max_seq_no", aggregations.Max(search.Field(SeqNoField)))
+
+	dmi, err := reader.Search(context.Background(), req)
+	if err != nil {
+		return 0, err
+	}
+
+	max := dmi.Aggregations().Metric("max_seq_no")
+	if math.IsInf(max, 0) || math.IsNaN(max) || max < 0 {
+		return 0, nil
+	}
+	return int64(max), nil
+}
@@ -2,129 +2,551 @@ package handlers
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
+	"net/http"
+	"sync"
+	"time"
 
-	"github.com/bingoohuang/gg/pkg/ss"
-	"github.com/prabhatsharma/zinc/pkg/zutil"
-
-	"github.com/blugelabs/bluge/index"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/prabhatsharma/zinc/pkg/core"
+	v1 "github.com/prabhatsharma/zinc/pkg/meta/v1"
+	"github.com/prabhatsharma/zinc/pkg/zutil"
 )
 
 func BulkHandler(c *gin.Context) {
-	result, err := BulkHandlerWorker(c.Param("target"), c.Request.Body)
+	opts := BulkOptions{
+		Pipeline:   c.Query("pipeline"),
+		ErrorsOnly: c.Query("items") == "errors",
+	}
+
+	resp, err := BulkHandlerWorker(c.Param("target"), opts, c.Request.Body)
 	if err != nil {
-		c.JSON(200, gin.H{"message": err})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(200, gin.H{
-		"message":     "bulk data inserted",
-		"updateCount": result.UpdateCount,
-		"insertCount": result.InsertCount,
+	c.JSON(http.StatusOK, resp)
+}
+
+// BulkOptions are the options of a bulk request.
+type BulkOptions struct {
+	// Pipeline, if not empty, is run on every document that does not request its own pipeline in its metadata line.
+	Pipeline string
+	// ErrorsOnly leaves the succeeded actions out of the items of the response, to keep the response of large requests small.
+	ErrorsOnly bool
+}
+
+// bulkLimits are the limits of the bulk requests, set by environment variables.
+type bulkLimits struct {
+	// flushDocs is the number of actions after which the batch is written to the index.
+	flushDocs int
+	// flushBytes is the size of the document lines after which the batch is written to the index.
+	flushBytes int
+	// maxLineBytes is the max size of a line, 0 for no limit. Longer document lines fail their action.
+	maxLineBytes int
+	// maxItems is the max number of items of a response, 0 for no limit. Further items are only counted.
+	maxItems int
+}
+
+var (
+	bulkLimitsOnce sync.Once
+	bulkLimitsEnv  bulkLimits
+)
+
+// getBulkLimits returns the limits of the bulk requests, read from the environment on the first request, once the
+// .env file is loaded.
+func getBulkLimits() bulkLimits {
+	bulkLimitsOnce.Do(func() {
+		bulkLimitsEnv = bulkLimits{
+			flushDocs:    zutil.GetEnvInt("ZINC_BULK_FLUSH_DOCS", 5000),
+			flushBytes:   zutil.GetEnvInt("ZINC_BULK_FLUSH_BYTES", 16*1024*1024),
+			maxLineBytes: zutil.GetEnvInt("ZINC_BULK_MAX_LINE_BYTES", 100*1024*1024),
+			maxItems:     zutil.GetEnvInt("ZINC_BULK_MAX_ITEMS", 100000),
+		}
 	})
+	return bulkLimitsEnv
 }
 
-type BulkResult struct {
-	UpdateCount int
-	InsertCount int
+// BulkResponse is the response of the bulk API, in the shape of the Elasticsearch bulk API.
+type BulkResponse struct {
+	Took   int64                  `json:"took"`   // milliseconds
+	Errors bool                   `json:"errors"` // true if any action failed
+	Items  []map[string]*BulkItem `json:"items"`  // the result of each action by its name, in the order of the request
+	// Counts counts the actions by their result, and the failed actions as failed
+	Counts map[string]int `json:"counts"`
+	// ItemsDropped counts the items left out of Items once it holds maxItems, they are only counted in Counts
+	ItemsDropped int `json:"items_dropped,omitempty"`
+
+	maxItems int
 }
 
-const _index = "_index"
+// BulkItem is the result of one action of a bulk request.
+type BulkItem struct {
+	Index   string     `json:"_index"`
+	ID      string     `json:"_id"`
+	Version int64      `json:"_version,omitempty"`
+	SeqNo   int64      `json:"_seq_no,omitempty"`
+	Result  string     `json:"result,omitempty"`
+	Status  int        `json:"status"`
+	Ignored []string   `json:"_ignored,omitempty"` // fields left out of the document because of a type conflict
+	Error   *BulkError `json:"error,omitempty"`
+	Line    int        `json:"line,omitempty"` // line of the request body the action starts at
+}
 
-func BulkHandlerWorker(target string, body io.ReadCloser) (*BulkResult, error) {
-	// Prepare to read the entire raw text of the body
-	scanner := bufio.NewScanner(body)
+// BulkError tells why an action of a bulk request failed.
+type BulkError struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
 
-	// Set 1 MB max per line. docs at - https://pkg.go.dev/bufio#pkg-constants
-	// This is the max size of a line in a file that we will process
-	const maxCapacityPerLine = 1024 * 1024
-	buf := make([]byte, maxCapacityPerLine)
-	scanner.Buffer(buf, maxCapacityPerLine)
+// Actions of a bulk request.
+const (
+	bulkIndex  = "index"  // index the document, replacing the document with the same id
+	bulkCreate = "create" // index the document, failing if a document with the same id exists
+	bulkUpdate = "update" // partially update the document
+	bulkDelete = "delete" // delete the document, the only action without a document line
+)
 
-	nextLineIsData := false
-	lastLineMetaData := make(map[string]interface{})
+// bulkMetadata is the metadata of an action, the object in its action line.
+type bulkMetadata struct {
+	Index       string `json:"_index"`
+	ID          string `json:"_id"`
+	Pipeline    string `json:"pipeline"`
+	IfSeqNo     *int64 `json:"if_seq_no"`
+	Version     *int64 `json:"version"`
+	VersionType string `json:"version_type"`
+}
+
+// BulkHandlerWorker runs the actions of the bulk request body.
+// The actions fail or succeed one by one, an error is returned only if the body is malformed or cannot be written.
+// The documents are written to the index in batches while the body is read: if the body turns out malformed, the
+// batches before the error are applied and the actions read since the last batch are dropped.
+func BulkHandlerWorker(target string, opts BulkOptions, body io.Reader) (*BulkResponse, error) {
+	start := time.Now()
 
-	batch := make(map[string]*index.Batch)
-	var indexesInThisBatch []string
-	var bulkResult BulkResult
+	read := &countingReader{Reader: body}
+	lines := newLineReader(read, getBulkLimits().maxLineBytes)
+	w := &bulkWriter{pipeline: opts.Pipeline, start: start, read: read}
 
-	for scanner.Scan() { // Read each line
-		var doc map[string]interface{}
-		err := json.Unmarshal(scanner.Bytes(), &doc) // Read each line as JSON and store it in doc
+	resp := newBulkResponse()
+	// fail reports the error of the body with the number of actions applied before it, the actions read since the
+	// last batch are dropped
+	fail := func(err error) (*BulkResponse, error) {
+		return nil, fmt.Errorf("%v, %d actions before it were applied", err, w.actions)
+	}
+
+	for {
+		line, err := lines.next()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			log.Print(err)
+			return fail(err)
 		}
 
-		// This will process the data line in the request. Each data line is preceded by a metadata line.
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		actionLineNumber := lines.number
+
+		// Each action line is followed by the document line of the action, except for delete.
 		// Docs at https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-bulk.html
-		if nextLineIsData {
-			nextLineIsData = false
-			id := ""
-			mintedID := false
-
-			if val, ok := lastLineMetaData["id"]; ok {
-				id = val.(string)
-			} else {
-				id = uuid.New().String()
-				mintedID = true
-			}
+		var actionLine map[string]bulkMetadata
+		if err := json.Unmarshal(line, &actionLine); err != nil || len(actionLine) != 1 {
+			return fail(fmt.Errorf("line %d: malformed action/metadata line, expected an object with one of %s, %s, %s or %s",
+				actionLineNumber, bulkCreate, bulkDelete, bulkIndex, bulkUpdate))
+		}
 
-			indexName := lastLineMetaData[_index].(string)
-			// Since this is a bulk request, we need to check if we already created a new batch for this index. We need to create 1 batch per index.
-			if !zutil.SliceContains(indexesInThisBatch, indexName) { // Add the list of indexes to the batch if it's not already there
-				indexesInThisBatch = append(indexesInThisBatch, indexName)
-				batch[indexName] = index.NewBatch()
-			}
+		var action string
+		var meta bulkMetadata
+		for action, meta = range actionLine {
+		}
 
-			idx, err := core.GetIndex(indexName)
-			if err != nil {
-				return nil, err
+		var data []byte
+		var dataErr error
+		switch action {
+		case bulkIndex, bulkCreate, bulkUpdate:
+			data, dataErr = lines.next()
+			if dataErr == io.EOF {
+				return fail(fmt.Errorf("line %d: %s action is missing its document line", actionLineNumber, action))
 			}
-
-			bdoc, err := idx.BuildBlugeDocFromJSON(id, &doc)
-			// Add the document to the batch. We will persist the batch to the index
-			// when we have processed all documents in the request
-			if !mintedID {
-				batch[indexName].Update(bdoc.ID(), bdoc)
-				bulkResult.UpdateCount++
-			} else {
-				batch[indexName].Insert(bdoc)
-				bulkResult.InsertCount++
+			if dataErr != nil && !errors.Is(dataErr, errLineTooLong) {
+				return fail(dataErr)
 			}
+		case bulkDelete:
+		default:
+			return fail(fmt.Errorf("line %d: unknown action [%s], expected one of %s, %s, %s or %s",
+				actionLineNumber, action, bulkCreate, bulkDelete, bulkIndex, bulkUpdate))
+		}
 
-		} else { // This branch will process the metadata line in the request. Each metadata line is preceded by a data line.
-			for k, v := range doc {
-				switch k {
-				case "index", "create", "update", "delete":
-					vm, _ := v.(map[string]interface{})
-					lastLineMetaData["operation"] = k
-					lastLineMetaData["_id"] = vm["_id"]
-					// if index is specified in metadata then it overtakes the index in the query path
-					lastLineMetaData[_index] = ss.Or(vm[_index].(string), target)
-					nextLineIsData = k != "delete"
+		// if index is specified in metadata then it overtakes the index in the query path
+		if meta.Index == "" {
+			meta.Index = target
+		}
+
+		item := &BulkItem{Index: meta.Index, ID: meta.ID, Line: actionLineNumber}
+		done := func(item *BulkItem) { resp.add(action, item, opts.ErrorsOnly) }
+		if dataErr != nil {
+			item.fail(http.StatusBadRequest, "mapper_parsing_exception", dataErr.Error())
+			err = w.add(action, meta, nil, v1.UpdateRequest{}, item, done)
+		} else {
+			err = w.do(action, meta, data, item, done)
+		}
+		if err != nil {
+			log.Print("Error updating batch: ", err.Error())
+			return fail(err)
+		}
+
+		if err := w.added(len(data)); err != nil {
+			log.Print("Error updating batch: ", err.Error())
+			return fail(err)
+		}
+	}
+
+	// Persist the batch to the index
+	if err := w.flush(); err != nil {
+		log.Print("Error updating batch: ", err.Error())
+		return fail(err)
+	}
+	if w.flushes > 0 {
+		w.logProgress()
+	}
+
+	resp.Took = time.Since(start).Milliseconds()
+	return resp, nil
+}
+
+func newBulkResponse() *BulkResponse {
+	return &BulkResponse{Items: []map[string]*BulkItem{}, Counts: make(map[string]int), maxItems: getBulkLimits().maxItems}
+}
+
+// add counts the result of the action and adds its item, unless only the failed items are reported and it succeeded.
+// Once the response holds maxItems items, the items are only counted, so the response of a large request stays small.
+func (resp *BulkResponse) add(action string, item *BulkItem, errorsOnly bool) {
+	if item.Error != nil {
+		resp.Errors = true
+		resp.Counts["failed"]++
+	} else {
+		resp.Counts[item.Result]++
+	}
+
+	if item.Error == nil && errorsOnly {
+		return
+	}
+	if resp.maxItems > 0 && len(resp.Items) >= resp.maxItems {
+		resp.ItemsDropped++
+		return
+	}
+	resp.Items = append(resp.Items, map[string]*BulkItem{action: item})
+}
+
+// bulkWriter runs the actions of a bulk request. The actions are validated, run through their pipeline and queued
+// while the body is read, without the document lock, and are written to the index when the queue is flushed, every
+// flushDocs actions or flushBytes of documents of the bulk limits. The document lock of an index is only held while a queue of
+// actions is written, so a slow client does not block the other writes to the index.
+type bulkWriter struct {
+	pipeline string
+	// stopOnError stops the actions of the queue at the first one that fails, the actions after it are left out
+	stopOnError bool
+	queue       []bulkAction
+
+	start        time.Time
+	read         *countingReader // the request body
+	actions      int             // actions that succeeded, in the batches written to the index
+	pendingDocs  int             // actions since the last flush
+	pendingBytes int             // size of the documents since the last flush
+	flushes      int             // flushes of the batch in the middle of the request
+}
+
+// bulkAction is an action of a bulk request waiting in the queue.
+type bulkAction struct {
+	action   string
+	index    *core.Index
+	meta     bulkMetadata
+	pre      core.Precondition
+	doc      map[string]interface{}
+	update   v1.UpdateRequest
+	mintedID bool
+	item     *BulkItem
+	// done, if not nil, is called with the item once the action ran or failed, in the order of the actions
+	done func(item *BulkItem)
+}
+
+// added counts an action that was queued and flushes the queue when it is due.
+func (w *bulkWriter) added(size int) error {
+	w.pendingDocs++
+	w.pendingBytes += size
+	if limits := getBulkLimits(); w.pendingDocs < limits.flushDocs && w.pendingBytes < limits.flushBytes {
+		return nil
+	}
+
+	if err := w.flush(); err != nil {
+		return err
+	}
+	w.flushes++
+	w.logProgress()
+
+	return nil
+}
+
+// logProgress logs the progress of a large request.
+func (w *bulkWriter) logProgress() {
+	elapsed := time.Since(w.start)
+	log.Printf("bulk: %d actions applied, %.1f MB read in %v (%.0f actions/s)", w.actions, float64(w.read.n)/1024/1024,
+		elapsed.Round(time.Millisecond), float64(w.actions)/elapsed.Seconds())
+}
+
+// do parses the document line of the action and queues it.
+func (w *bulkWriter) do(action string, meta bulkMetadata, data []byte, item *BulkItem, done func(item *BulkItem)) error {
+	var doc map[string]interface{}
+	var update v1.UpdateRequest
+	var err error
+	switch action {
+	case bulkIndex, bulkCreate:
+		err = json.Unmarshal(data, &doc)
+	case bulkUpdate:
+		err = json.Unmarshal(data, &update)
+	}
+	if err != nil {
+		item.fail(http.StatusBadRequest, "mapper_parsing_exception", "failed to parse document: "+err.Error())
+	}
+
+	return w.add(action, meta, doc, update, item, done)
+}
+
+// add validates the action, runs its document through its pipeline and queues it. The actions that already failed
+// or have nothing to write are queued as well, so that done is called in the order of the actions.
+// Only errors that fail the whole request are returned.
+func (w *bulkWriter) add(action string, meta bulkMetadata, doc map[string]interface{}, update v1.UpdateRequest, item *BulkItem, done func(item *BulkItem)) error {
+	a := bulkAction{action: action, meta: meta, doc: doc, update: update, item: item, done: done}
+	if item.Error == nil {
+		if err := w.prepare(&a); err != nil {
+			return err
+		}
+	}
+
+	w.queue = append(w.queue, a)
+	return nil
+}
+
+// prepare validates the action and runs its document through its pipeline, recording the result in the item if the
+// action fails or has nothing to write. Only errors that fail the whole request are returned.
+func (w *bulkWriter) prepare(a *bulkAction) error {
+	meta, item := a.meta, a.item
+	if meta.Index == "" {
+		item.fail(http.StatusBadRequest, "action_request_validation_exception", "index is missing")
+		return nil
+	}
+	if meta.ID == "" && (a.action == bulkUpdate || a.action == bulkDelete) {
+		item.fail(http.StatusBadRequest, "action_request_validation_exception", "id is missing")
+		return nil
+	}
+
+	a.pre = core.Precondition{IfSeqNo: meta.IfSeqNo, Version: meta.Version, VersionType: meta.VersionType}
+	if err := a.pre.Validate(); err != nil {
+		item.fail(http.StatusBadRequest, "action_request_validation_exception", err.Error())
+		return nil
+	}
+
+	// Deletes and updates do not create the index, unless the update upserts the document
+	var err error
+	index, ok := core.FindIndex(meta.Index)
+	switch {
+	case !ok && a.action == bulkDelete:
+		item.Result, item.Status = core.ResultNotFound, http.StatusNotFound
+		return nil
+	case !ok && a.action == bulkUpdate && a.update.Upsert == nil && !a.update.DocAsUpsert:
+		item.fail(http.StatusNotFound, "document_missing_exception", fmt.Sprintf("[%s]: %v", meta.ID, core.ErrDocNotFound))
+		return nil
+	case !ok:
+		if index, err = core.GetIndex(meta.Index); err != nil {
+			return err
+		}
+	}
+	a.index = index
+
+	if a.action != bulkIndex && a.action != bulkCreate {
+		return nil
+	}
+	if meta.ID == "" {
+		item.ID, a.mintedID = uuid.New().String(), true
+	}
+
+	pipeline := meta.Pipeline
+	if pipeline == "" {
+		pipeline = w.pipeline
+	}
+	if a.doc, err = index.ProcessDoc(pipeline, a.doc); err != nil {
+		item.fail(http.StatusBadRequest, "illegal_argument_exception", err.Error())
+		return nil
+	}
+	if a.doc == nil { // dropped by the pipeline
+		item.Result, item.Status = core.ResultNoop, http.StatusOK
+	}
+
+	return nil
+}
+
+// flush writes the queued actions to their index and persists them. The actions are written to one index at a time:
+// the writer of an index holds its document lock until it is flushed, so it is flushed before the actions move to
+// another index.
+func (w *bulkWriter) flush() error {
+	queue := w.queue
+	w.queue = nil
+
+	var writer *core.DocWriter
+	var writerIndex *core.Index
+	for i, a := range queue {
+		if a.item.Error == nil && a.item.Result == "" {
+			if writer != nil && writerIndex != a.index {
+				if err := writer.Flush(); err != nil {
+					return err
 				}
+				writer = nil
 			}
+			if writer == nil {
+				writer, writerIndex = a.index.NewDocWriter(), a.index
+			}
+			a.run(writer)
+		}
+
+		if a.done != nil {
+			a.done(a.item)
+		}
+		if a.item.Error != nil && w.stopOnError {
+			queue = queue[:i+1]
+			break
+		}
+	}
+	if writer != nil {
+		if err := writer.Flush(); err != nil {
+			return err
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, err
+	for _, a := range queue {
+		if a.item.Error == nil {
+			w.actions++
+		}
 	}
+	w.pendingDocs, w.pendingBytes = 0, 0
 
-	for _, n := range indexesInThisBatch {
-		writer := core.ZincIndexList[n].Writer
+	return nil
+}
 
-		// Persist the batch to the index
-		if err := writer.Batch(batch[n]); err != nil {
-			log.Print("Error updating batch: ", err.Error())
+// run writes the action with the writer of its index and records its result in the item.
+func (a *bulkAction) run(writer *core.DocWriter) {
+	var result core.WriteResult
+	var err error
+	switch a.action {
+	case bulkIndex:
+		result, err = writer.Index(a.item.ID, a.doc, a.mintedID, a.pre)
+	case bulkCreate:
+		result, err = writer.Create(a.item.ID, a.doc, a.mintedID)
+	case bulkUpdate:
+		result, err = writer.Update(a.meta.ID, a.update.Doc, a.update.Upsert, a.update.DocAsUpsert, a.pre)
+	case bulkDelete:
+		result, err = writer.Delete(a.meta.ID, a.pre)
+	}
+	if err != nil {
+		a.item.failWith(err)
+		return
+	}
+
+	a.item.Version, a.item.SeqNo, a.item.Result, a.item.Ignored = result.Version, result.SeqNo, result.Result, result.Ignored
+	switch result.Result {
+	case core.ResultCreated:
+		a.item.Status = http.StatusCreated
+	case core.ResultNotFound:
+		a.item.Status = http.StatusNotFound
+	default:
+		a.item.Status = http.StatusOK
+	}
+}
+
+// errLineTooLong is returned for a line longer than the max line size.
+var errLineTooLong = errors.New("line too long")
+
+// lineReader reads the lines of a request body. Lines can be longer than its buffer, up to the max line size.
+type lineReader struct {
+	r       *bufio.Reader
+	line    []byte
+	maxSize int // 0 for no limit
+	number  int // number of the last line read
+}
+
+func newLineReader(r io.Reader, maxSize int) *lineReader {
+	return &lineReader{r: bufio.NewReaderSize(r, 64*1024), maxSize: maxSize}
+}
+
+// next returns the next line. The line is valid until the next call.
+// A line longer than the max size is skipped and an error wrapping errLineTooLong is returned.
+func (l *lineReader) next() ([]byte, error) {
+	l.line = l.line[:0]
+	tooLong := false
+	for {
+		chunk, err := l.r.ReadSlice('\n')
+		if !tooLong {
+			l.line = append(l.line, chunk...)
+			if l.maxSize > 0 && len(l.line) > l.maxSize {
+				tooLong, l.line = true, l.line[:0]
+			}
+		}
+
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF && (len(chunk) > 0 || len(l.line) > 0 || tooLong) {
+			err = nil // the last line has no newline
+		}
+		if err != nil {
 			return nil, err
 		}
+
+		l.number++
+		if tooLong {
+			return nil, fmt.Errorf("line %d: %w, the limit is %d bytes", l.number, errLineTooLong, l.maxSize)
+		}
+		return l.line, nil
 	}
+}
+
+func (item *BulkItem) fail(status int, errType, reason string) {
+	item.Status = status
+	item.Error = &BulkError{Type: errType, Reason: reason}
+}
+
+// failWith records the error of a document write.
+func (item *BulkItem) failWith(err error) {
+	var versionConflict *core.VersionConflictError
+	var typeConflict *core.TypeConflictError
+	switch {
+	case errors.As(err, &versionConflict):
+		item.fail(http.StatusConflict, "version_conflict_engine_exception", err.Error())
+	case errors.Is(err, core.ErrDocNotFound):
+		item.fail(http.StatusNotFound, "document_missing_exception", fmt.Sprintf("[%s]: %v", item.ID, err))
+	case errors.As(err, &typeConflict):
+		item.fail(http.StatusBadRequest, "mapper_parsing_exception", err.Error())
+	default:
+		item.fail(http.StatusBadRequest, "illegal_argument_exception", err.Error())
+	}
+}
+
+// countingReader counts the bytes read from the reader.
+type countingReader struct {
+	io.Reader
+	n int64
+}
 
-	return &bulkResult, nil
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += int64(n)
+	return n, err
 }
@@ -1,14 +1,17 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/google/uuid"
 
-	"github.com/blugelabs/bluge"
 	"github.com/gin-gonic/gin"
 	"github.com/prabhatsharma/zinc/pkg/core"
+	v1 "github.com/prabhatsharma/zinc/pkg/meta/v1"
 )
 
 func UpdateDoc(c *gin.Context) {
@@ -23,11 +26,114 @@ func UpdateDoc(c *gin.Context) {
 	c.BindJSON(&doc)
 
 	docID, mintedID := parseDocID(doc, c.Param("id"))
-	if err := index.UpdateDoc(docID, &doc, mintedID); err != nil {
-		c.JSON(http.StatusInternalServerError, err)
+
+	pre, err := parsePrecondition(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "id": docID})
+		return
+	}
+
+	doc, err = index.ProcessDoc(c.Query("pipeline"), doc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "id": docID})
+		return
+	}
+	if doc == nil {
+		c.JSON(http.StatusOK, gin.H{"id": docID, "dropped": true})
+		return
+	}
+
+	policy := index.Settings.TypeConflictPolicy()
+	if result, err := index.UpdateDoc(docID, &doc, mintedID, pre); err != nil {
+		c.JSON(writeErrorStatus(err), gin.H{"error": err.Error(), "id": docID, "type_conflict": policy})
 	} else {
-		c.JSON(http.StatusOK, gin.H{"id": docID})
+		c.JSON(http.StatusOK, gin.H{
+			"id":              docID,
+			"result":          result.Result,
+			"_version":        result.Version,
+			"_seq_no":         result.SeqNo,
+			"type_conflict":   policy,
+			core.IgnoredField: result.Ignored,
+		})
+	}
+}
+
+// UpdateDocPartial deep merges a partial document into the stored document, or upserts it if it does not exist.
+func UpdateDocPartial(c *gin.Context) {
+	indexName := c.Param("target")
+	docID := c.Param("id")
+
+	var req v1.UpdateRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pre, err := parsePrecondition(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	index, ok := core.FindIndex(indexName)
+	if !ok {
+		if req.Upsert == nil && !req.DocAsUpsert {
+			c.JSON(http.StatusNotFound, gin.H{"error": "index '" + indexName + "' does not exist"})
+			return
+		}
+
+		if index, err = core.GetIndex(indexName); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	result, err := index.PartialUpdateDoc(docID, req.Doc, req.Upsert, req.DocAsUpsert, pre)
+	if err != nil {
+		c.JSON(writeErrorStatus(err), gin.H{"error": err.Error(), "_index": indexName, "_id": docID})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"_index":          indexName,
+		"_id":             docID,
+		"result":          result.Result,
+		"_version":        result.Version,
+		"_seq_no":         result.SeqNo,
+		"type_conflict":   index.Settings.TypeConflictPolicy(),
+		core.IgnoredField: result.Ignored,
+	})
+}
+
+// parsePrecondition parses the optimistic concurrency control parameters of a write from the query string.
+func parsePrecondition(c *gin.Context) (core.Precondition, error) {
+	pre := core.Precondition{VersionType: c.Query("version_type")}
+	for param, dst := range map[string]**int64{"if_seq_no": &pre.IfSeqNo, "version": &pre.Version} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return pre, fmt.Errorf("invalid %s [%s]", param, value)
+		}
+		*dst = &n
+	}
+
+	return pre, pre.Validate()
+}
+
+// writeErrorStatus returns the HTTP status for the error of a document write.
+func writeErrorStatus(err error) int {
+	var conflict *core.VersionConflictError
+	switch {
+	case errors.As(err, &conflict):
+		return http.StatusConflict
+	case errors.Is(err, core.ErrDocNotFound):
+		return http.StatusNotFound
 	}
+
+	return http.StatusBadRequest
 }
 
 // parseDocID parse id field is present then use it, else create a new UUID and use it.
@@ -55,11 +161,106 @@ func DeleteDoc(c *gin.Context) {
 		return
 	}
 
-	bdoc := bluge.NewDocument(queryId)
-	bdoc.AddField(bluge.NewCompositeFieldExcluding("_all", nil))
-	if err := index.Writer.Delete(bdoc.ID()); err != nil {
+	pre, err := parsePrecondition(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := index.DeleteDoc(queryId, pre)
+	var conflict *core.VersionConflictError
+	switch {
+	case errors.As(err, &conflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "index": indexName, "id": queryId})
+	case err != nil:
 		c.JSON(http.StatusInternalServerError, err)
-	} else {
-		c.JSON(http.StatusOK, gin.H{"message": "Deleted", "index": indexName, "id": queryId})
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"message":  "Deleted",
+			"index":    indexName,
+			"id":       queryId,
+			"result":   result.Result,
+			"_version": result.Version,
+			"_seq_no":  result.SeqNo,
+		})
+	}
+}
+
+// GetDoc returns the document with the id, or 404 if the index or the document does not exist.
+func GetDoc(c *gin.Context) {
+	indexName := c.Param("target")
+	docID := c.Param("id")
+	index, ok := core.FindIndex(indexName)
+	if !ok {
+		c.JSON(http.StatusNotFound, v1.Doc{Index: indexName, Type: indexName, ID: docID, Error: "index '" + indexName + "' does not exist"})
+		return
+	}
+
+	doc, err := index.GetDoc(docID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !doc.Found {
+		c.JSON(http.StatusNotFound, doc)
+		return
+	}
+
+	c.JSON(http.StatusOK, doc)
+}
+
+// MultiGetDocs returns many documents, possibly of different indexes, in the order they are requested.
+func MultiGetDocs(c *gin.Context) {
+	var req v1.MultiGetRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	target := c.Param("target")
+	refs := req.Docs
+	for _, id := range req.IDs {
+		refs = append(refs, v1.DocRef{Index: target, ID: id})
+	}
+
+	// Fetch the documents of each index with a single reader
+	positions := make(map[string][]int)
+	var indexNames []string
+	for i := range refs {
+		if refs[i].Index == "" {
+			refs[i].Index = target
+		}
+		if _, ok := positions[refs[i].Index]; !ok {
+			indexNames = append(indexNames, refs[i].Index)
+		}
+		positions[refs[i].Index] = append(positions[refs[i].Index], i)
 	}
+
+	docs := make([]v1.Doc, len(refs))
+	for _, indexName := range indexNames {
+		index, ok := core.FindIndex(indexName)
+		ids := make([]string, len(positions[indexName]))
+		for j, i := range positions[indexName] {
+			ids[j] = refs[i].ID
+			docs[i] = v1.Doc{Index: indexName, Type: indexName, ID: refs[i].ID}
+			if !ok {
+				docs[i].Error = "index '" + indexName + "' does not exist"
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		found, err := index.GetDocs(ids)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for j, i := range positions[indexName] {
+			docs[i] = found[j]
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"docs": docs})
 }
@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prabhatsharma/zinc/pkg/core"
+	v1 "github.com/prabhatsharma/zinc/pkg/meta/v1"
+	"github.com/prabhatsharma/zinc/pkg/zutil"
+)
+
+// esVersion returns the Elasticsearch version reported to clients, which check it before sending requests.
+func esVersion() string {
+	return zutil.GetEnv("ZINC_ES_VERSION", "7.10.2")
+}
+
+// esShards is the shard summary of Elasticsearch responses. A Zinc index is a single shard.
+var esShards = gin.H{"total": 1, "successful": 1, "skipped": 0, "failed": 0}
+
+// ESHeaders marks the responses of the Elasticsearch compatible API as coming from Elasticsearch, which newer clients require.
+func ESHeaders(c *gin.Context) {
+	c.Header("X-Elastic-Product", "Elasticsearch")
+	c.Next()
+}
+
+// ESInfo returns the cluster information of the Elasticsearch root endpoint. Browsers are redirected to the UI.
+func ESInfo(c *gin.Context) {
+	if strings.Contains(c.GetHeader("Accept"), "text/html") {
+		c.Redirect(http.StatusMovedPermanently, "/ui/")
+		return
+	}
+
+	name, _ := os.Hostname()
+	c.JSON(http.StatusOK, gin.H{
+		"name":         name,
+		"cluster_name": "zinc",
+		"cluster_uuid": "zinc",
+		"version": gin.H{
+			"number":                              esVersion(),
+			"build_flavor":                        "default",
+			"build_type":                          "zinc",
+			"build_hash":                          v1.GitCommit,
+			"build_date":                          v1.BuildTime,
+			"build_snapshot":                      false,
+			"lucene_version":                      "8.7.0",
+			"minimum_wire_compatibility_version":  "6.8.0",
+			"minimum_index_compatibility_version": "6.0.0-beta1",
+			"zinc_version":                        v1.AppVersion,
+		},
+		"tagline": "You Know, for Search",
+	})
+}
+
+// ESWriteDoc indexes, creates, updates or deletes a single document with the Elasticsearch document APIs.
+// The action is taken from the path: _doc, _create or _update, and the method for deletes.
+func ESWriteDoc(c *gin.Context) {
+	action := bulkIndex
+	switch {
+	case c.Request.Method == http.MethodDelete:
+		action = bulkDelete
+	case strings.Contains(c.FullPath(), "/_create/"), c.Query("op_type") == bulkCreate:
+		action = bulkCreate
+	case strings.Contains(c.FullPath(), "/_update/"):
+		action = bulkUpdate
+	}
+
+	pre, err := parsePrecondition(c)
+	if err != nil {
+		esError(c, http.StatusBadRequest, "action_request_validation_exception", err.Error())
+		return
+	}
+	meta := bulkMetadata{
+		Index:       c.Param("target"),
+		ID:          c.Param("id"),
+		Pipeline:    c.Query("pipeline"),
+		IfSeqNo:     pre.IfSeqNo,
+		Version:     pre.Version,
+		VersionType: pre.VersionType,
+	}
+
+	var data []byte
+	if action != bulkDelete {
+		if data, err = io.ReadAll(c.Request.Body); err != nil {
+			esError(c, http.StatusBadRequest, "parse_exception", err.Error())
+			return
+		}
+	}
+
+	item := &BulkItem{Index: meta.Index, ID: meta.ID}
+	w := &bulkWriter{}
+	if err = w.do(action, meta, data, item, nil); err == nil {
+		err = w.flush()
+	}
+	if err != nil {
+		esError(c, http.StatusInternalServerError, "exception", err.Error())
+		return
+	}
+
+	if item.Error != nil {
+		esError(c, item.Status, item.Error.Type, item.Error.Reason)
+		return
+	}
+	c.JSON(item.Status, gin.H{
+		"_index":        item.Index,
+		"_type":         "_doc",
+		"_id":           item.ID,
+		"_version":      item.Version,
+		"result":        item.Result,
+		"_shards":       esShards,
+		"_seq_no":       item.SeqNo,
+		"_primary_term": 1,
+	})
+}
+
+// ESSearch searches the index with a request of the Elasticsearch query DSL, or with the q query string parameter.
+func ESSearch(c *gin.Context) {
+	name := c.Param("target")
+	index, ok := core.FindIndex(name)
+	if !ok {
+		esIndexNotFound(c, name)
+		return
+	}
+
+	var query v1.DSLQuery
+	if err := json.NewDecoder(c.Request.Body).Decode(&query); err != nil && err != io.EOF {
+		esError(c, http.StatusBadRequest, "parse_exception", err.Error())
+		return
+	}
+	if q := c.Query("q"); q != "" {
+		query.Query = map[string]interface{}{"query_string": map[string]interface{}{"query": q}}
+	}
+	if from := c.Query("from"); from != "" {
+		n, err := strconv.Atoi(from)
+		if err != nil {
+			esError(c, http.StatusBadRequest, "illegal_argument_exception", "invalid from ["+from+"]")
+			return
+		}
+		query.From = n
+	}
+	if size := c.Query("size"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil {
+			esError(c, http.StatusBadRequest, "illegal_argument_exception", "invalid size ["+size+"]")
+			return
+		}
+		query.Size = &n
+	}
+
+	res, err := index.SearchDSL(query)
+	if err != nil {
+		esError(c, http.StatusBadRequest, "search_phase_execution_exception", err.Error())
+		return
+	}
+
+	hits := res.Hits.Hits
+	if hits == nil {
+		hits = []v1.Hit{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"took":      res.Took,
+		"timed_out": false,
+		"_shards":   esShards,
+		"hits": gin.H{
+			"total":     gin.H{"value": res.Hits.Total.Value, "relation": "eq"},
+			"max_score": res.MaxScore,
+			"hits":      hits,
+		},
+	})
+}
+
+// ESCatIndices lists the indexes like the _cat/indices API: as a text table, with a header row if v is set,
+// or as JSON with format=json.
+func ESCatIndices(c *gin.Context) {
+	names := make([]string, 0, len(core.ZincIndexList))
+	for name := range core.ZincIndexList {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	columns := []string{"health", "status", "index", "uuid", "pri", "rep", "docs.count", "docs.deleted", "store.size", "pri.store.size"}
+	rows := make([]map[string]string, 0, len(names))
+	for _, name := range names {
+		index, ok := core.FindIndex(name)
+		if !ok {
+			continue
+		}
+		count, err := index.DocCount()
+		if err != nil {
+			esError(c, http.StatusInternalServerError, "exception", err.Error())
+			return
+		}
+		size := formatBytes(index.StorageSize())
+		rows = append(rows, map[string]string{
+			"health": "green", "status": "open", "index": name, "uuid": name, "pri": "1", "rep": "0",
+			"docs.count": strconv.FormatUint(count, 10), "docs.deleted": "0", "store.size": size, "pri.store.size": size,
+		})
+	}
+
+	if c.Query("format") == "json" {
+		c.JSON(http.StatusOK, rows)
+		return
+	}
+
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 1, ' ', 0)
+	if _, ok := c.GetQuery("v"); ok {
+		fmt.Fprintln(tw, strings.Join(columns, "\t"))
+	}
+	for _, row := range rows {
+		values := make([]string, len(columns))
+		for i, column := range columns {
+			values[i] = row[column]
+		}
+		fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+	tw.Flush()
+	c.String(http.StatusOK, b.String())
+}
+
+// ESGetMapping returns the mapping of the index with the Elasticsearch names of the field types.
+func ESGetMapping(c *gin.Context) {
+	name := c.Param("target")
+	index, ok := core.FindIndex(name)
+	if !ok {
+		esIndexNotFound(c, name)
+		return
+	}
+
+	properties := map[string]gin.H{core.TimestampField: {"type": "date"}}
+	for field, prop := range index.GetMappings().Properties {
+		switch prop.Type {
+		case core.FieldTypeNumeric:
+			properties[field] = gin.H{"type": "double"}
+		case core.FieldTypeDate, "time":
+			properties[field] = gin.H{"type": "date"}
+		case core.FieldTypeBool:
+			properties[field] = gin.H{"type": "boolean"}
+		case core.FieldTypeStored:
+			properties[field] = gin.H{"type": "object", "enabled": false}
+		case core.FieldTypeText:
+			properties[field] = gin.H{"type": "text"}
+			if prop.Analyzer != "" {
+				properties[field]["analyzer"] = prop.Analyzer
+			}
+		default:
+			properties[field] = gin.H{"type": prop.Type}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{name: gin.H{"mappings": gin.H{"properties": properties}}})
+}
+
+// ESAcknowledge accepts the mapping, template and other cluster setup requests of clients without applying them.
+// Zinc infers the field types, use the _mapping API of Zinc to declare them.
+func ESAcknowledge(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"acknowledged": true})
+}
+
+// ESGetTemplate reports every template as missing, the clients that check for their template then upload it.
+func ESGetTemplate(c *gin.Context) {
+	if c.Request.Method == http.MethodHead {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	c.JSON(http.StatusNotFound, gin.H{})
+}
+
+// ESClusterHealth returns a green cluster health, used by clients as a health check.
+func ESClusterHealth(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"cluster_name":         "zinc",
+		"status":               "green",
+		"timed_out":            false,
+		"number_of_nodes":      1,
+		"number_of_data_nodes": 1,
+		"active_shards":        len(core.ZincIndexList),
+	})
+}
+
+// ESXPack reports a basic license without ILM, so that clients do not set up index lifecycle policies.
+func ESXPack(c *gin.Context) {
+	license := gin.H{"uid": "zinc", "type": "basic", "mode": "basic", "status": "active"}
+	if strings.HasSuffix(c.FullPath(), "_license") {
+		c.JSON(http.StatusOK, gin.H{"license": license})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"build":    gin.H{"hash": v1.GitCommit, "date": v1.BuildTime},
+		"license":  license,
+		"features": gin.H{"ilm": gin.H{"available": false, "enabled": false}},
+	})
+}
+
+// esError responds with an error in the format of Elasticsearch.
+func esError(c *gin.Context, status int, errType, reason string) {
+	c.JSON(status, gin.H{
+		"error":  gin.H{"root_cause": []BulkError{{Type: errType, Reason: reason}}, "type": errType, "reason": reason},
+		"status": status,
+	})
+}
+
+func esIndexNotFound(c *gin.Context, name string) {
+	esError(c, http.StatusNotFound, "index_not_found_exception", "no such index ["+name+"]")
+}
+
+// formatBytes formats a size like Elasticsearch, e.g. 12.5kb.
+func formatBytes(size int64) string {
+	units := []string{"b", "kb", "mb", "gb", "tb"}
+	value := float64(size)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	if i == 0 {
+		return strconv.FormatInt(size, 10) + "b"
+	}
+	return strconv.FormatFloat(value, 'f', 1, 64) + units[i]
+}
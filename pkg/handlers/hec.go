@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	v1 "github.com/prabhatsharma/zinc/pkg/meta/v1"
+	"github.com/prabhatsharma/zinc/pkg/zutil"
+)
+
+// hecSettings returns the index of the events that do not name one and the ingest pipeline
+// of the Splunk HTTP Event Collector API.
+func hecSettings() (index, pipeline string) {
+	return zutil.GetEnv("ZINC_HEC_INDEX", "main"), zutil.GetEnv("ZINC_HEC_PIPELINE", "")
+}
+
+// hecEvent is an event of the Splunk HTTP Event Collector. The metadata fields default to the query parameters
+// of the same name.
+type hecEvent struct {
+	Time       json.RawMessage        `json:"time"` // seconds since the epoch, as a number or a string
+	Host       string                 `json:"host"`
+	Source     string                 `json:"source"`
+	SourceType string                 `json:"sourcetype"`
+	Index      string                 `json:"index"`
+	Event      interface{}            `json:"event"`
+	Fields     map[string]interface{} `json:"fields"`
+}
+
+// HECEvent indexes the JSON events of the request body, which follow each other with or without newlines.
+// Events before an invalid event are indexed, as by Splunk.
+func HECEvent(c *gin.Context) {
+	defaults := hecDefaults(c)
+	read := &countingReader{Reader: c.Request.Body}
+	w := newHECWriter(read)
+
+	dec := json.NewDecoder(read)
+	n := 0
+	for ; ; n++ {
+		offset := dec.InputOffset()
+		var e hecEvent
+		err := dec.Decode(&e)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			w.fail(c, http.StatusBadRequest, 6, "Invalid data format", n)
+			return
+		}
+		if e.Event == nil {
+			w.fail(c, http.StatusBadRequest, 12, "Event field is required", n)
+			return
+		}
+		if s, ok := e.Event.(string); ok && s == "" {
+			w.fail(c, http.StatusBadRequest, 13, "Event field cannot be blank", n)
+			return
+		}
+
+		if !w.write(c, &e, defaults, int(dec.InputOffset()-offset), n) {
+			return
+		}
+	}
+
+	w.success(c, n)
+}
+
+// HECRaw indexes every line of the request body as an event, with the metadata of the query parameters.
+func HECRaw(c *gin.Context) {
+	defaults := hecDefaults(c)
+	read := &countingReader{Reader: c.Request.Body}
+	w := newHECWriter(read)
+
+	lines := newLineReader(read, getBulkLimits().maxLineBytes)
+	n := 0
+	for {
+		line, err := lines.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil { // a read error or a line longer than the max line size
+			w.fail(c, http.StatusBadRequest, 6, "Invalid data format: "+err.Error(), n)
+			return
+		}
+		if line = bytes.TrimRight(line, "\r\n"); len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		e := hecEvent{Event: string(line)}
+		if !w.write(c, &e, defaults, len(line), n) {
+			return
+		}
+		n++
+	}
+
+	w.success(c, n)
+}
+
+// HECHealth reports that the collector is up, for load balancers and the health checks of clients.
+func HECHealth(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"text": "HEC is healthy", "code": 17})
+}
+
+// hecDefaults returns the metadata of the query parameters.
+func hecDefaults(c *gin.Context) hecEvent {
+	return hecEvent{Host: c.Query("host"), Source: c.Query("source"), SourceType: c.Query("sourcetype"), Index: c.Query("index")}
+}
+
+// hecWriter indexes the events of a request in order, up to the first event that fails.
+type hecWriter struct {
+	*bulkWriter
+	index        string // index of the events that do not name one
+	failed       bool
+	failedEvent  int // number of the event that failed
+	failedReason string
+}
+
+func newHECWriter(read *countingReader) *hecWriter {
+	index, pipeline := hecSettings()
+	return &hecWriter{bulkWriter: &bulkWriter{pipeline: pipeline, start: time.Now(), read: read, stopOnError: true}, index: index}
+}
+
+// write queues the event, the nth of the request. It responds with the error and returns false if the event, or an
+// event before it, failed.
+func (w *hecWriter) write(c *gin.Context, e *hecEvent, defaults hecEvent, size, n int) bool {
+	index, doc, err := e.doc(defaults, w.index, time.Now())
+	if err != nil {
+		w.fail(c, http.StatusBadRequest, 6, "Invalid data format: "+err.Error(), n)
+		return false
+	}
+	// The indexes starting with _ are the system indexes of Zinc, e.g. _users, which HEC tokens must not write to.
+	if strings.HasPrefix(index, "_") {
+		w.fail(c, http.StatusBadRequest, 7, "Incorrect index", n)
+		return false
+	}
+
+	item := &BulkItem{Index: index}
+	done := func(item *BulkItem) {
+		if item.Error != nil && !w.failed {
+			w.failed, w.failedEvent, w.failedReason = true, n, item.Error.Reason
+		}
+	}
+	if err := w.add(bulkIndex, bulkMetadata{Index: index}, doc, v1.UpdateRequest{}, item, done); err != nil {
+		hecError(c, http.StatusInternalServerError, 8, "Internal server error: "+err.Error(), n)
+		return false
+	}
+	if err := w.added(size); err != nil {
+		hecError(c, http.StatusInternalServerError, 8, "Internal server error: "+err.Error(), n)
+		return false
+	}
+	if w.failed {
+		hecError(c, http.StatusBadRequest, 6, "Invalid data format: "+w.failedReason, w.failedEvent)
+		return false
+	}
+	return true
+}
+
+// fail indexes the events before the nth event, which is invalid, and responds with the error of the first event
+// that failed.
+func (w *hecWriter) fail(c *gin.Context, status, code int, text string, n int) {
+	if err := w.flush(); err != nil {
+		hecError(c, http.StatusInternalServerError, 8, "Internal server error: "+err.Error(), n)
+		return
+	}
+	if w.failed {
+		hecError(c, http.StatusBadRequest, 6, "Invalid data format: "+w.failedReason, w.failedEvent)
+		return
+	}
+	hecError(c, status, code, text, n)
+}
+
+// success indexes the events of the request and responds, or reports a request without events.
+func (w *hecWriter) success(c *gin.Context, n int) {
+	if n == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"text": "No data", "code": 5})
+		return
+	}
+	if err := w.flush(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"text": "Internal server error: " + err.Error(), "code": 8})
+		return
+	}
+	if w.failed {
+		hecError(c, http.StatusBadRequest, 6, "Invalid data format: "+w.failedReason, w.failedEvent)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"text": "Success", "code": 0})
+}
+
+// hecError responds with an error of the Splunk HEC, with the number of the event that failed.
+func hecError(c *gin.Context, status, code int, text string, n int) {
+	c.JSON(status, gin.H{"text": text, "code": code, "invalid-event-number": n})
+}
+
+// doc returns the index and the document of the event. The fields of an object event and of fields are the fields
+// of the document, other events are its event field. time is the @timestamp of the document, or else now, and host,
+// source, sourcetype and index are fields of the document. Events without an index go to defaultIndex.
+func (e *hecEvent) doc(defaults hecEvent, defaultIndex string, now time.Time) (string, map[string]interface{}, error) {
+	timestamp := now
+	if len(e.Time) > 0 && string(e.Time) != "null" {
+		var err error
+		if timestamp, err = parseHECTime(e.Time); err != nil {
+			return "", nil, err
+		}
+	}
+
+	doc := make(map[string]interface{})
+	if event, ok := e.Event.(map[string]interface{}); ok {
+		for field, value := range event {
+			doc[field] = value
+		}
+	} else {
+		doc["event"] = e.Event
+	}
+	for field, value := range e.Fields {
+		doc[field] = value
+	}
+
+	doc["@timestamp"] = timestamp.UTC().Format(time.RFC3339Nano)
+	index := defaultIndex
+	for _, meta := range []struct{ field, value, defaultValue string }{
+		{"host", e.Host, defaults.Host},
+		{"source", e.Source, defaults.Source},
+		{"sourcetype", e.SourceType, defaults.SourceType},
+		{"index", e.Index, defaults.Index},
+	} {
+		value := meta.value
+		if value == "" {
+			value = meta.defaultValue
+		}
+		if value == "" {
+			continue
+		}
+		doc[meta.field] = value
+		if meta.field == "index" {
+			index = value
+		}
+	}
+
+	return index, doc, nil
+}
+
+// parseHECTime parses the seconds since the epoch with an optional fraction, e.g. 1700000000.123.
+func parseHECTime(data json.RawMessage) (time.Time, error) {
+	s := string(bytes.Trim(data, `"`))
+	seconds, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return time.Time{}, fmt.Errorf("invalid time [%s]", s)
+	}
+	sec, frac := math.Modf(seconds)
+	return time.Unix(int64(sec), int64(math.Round(frac*1e3))*int64(time.Millisecond)), nil
+}
@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/prabhatsharma/zinc/pkg/core"
+	v1 "github.com/prabhatsharma/zinc/pkg/meta/v1"
+	"github.com/prabhatsharma/zinc/pkg/zutil"
+)
+
+// Formats of the import API.
+const (
+	ImportCSV    = "csv"    // comma separated values, with a header row naming the columns
+	ImportNDJSON = "ndjson" // one JSON document per line, without action lines
+)
+
+// ImportOptions are the options of an import request.
+type ImportOptions struct {
+	Format   string
+	Pipeline string
+	// IDField is the field, or the CSV column, whose value is the id of the document.
+	IDField string
+	// IDTemplate builds the id of the document from its fields, e.g. {{host}}-{{seq}}. Used if IDField is empty.
+	IDTemplate string
+
+	// CSV options
+	Delimiter rune
+	Header    bool              // the first row names the columns
+	Columns   []string          // names of the columns, replacing the header row if any
+	Types     map[string]string // converts the values of the columns to one of core.ConvertTypes, else they are strings
+}
+
+// ImportHandler indexes the CSV or plain NDJSON documents of the request body.
+func ImportHandler(c *gin.Context) {
+	opts, err := parseImportOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := ImportWorker(c.Param("target"), opts, c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func parseImportOptions(c *gin.Context) (ImportOptions, error) {
+	opts := ImportOptions{
+		Format:     c.Query("format"),
+		Pipeline:   c.Query("pipeline"),
+		IDField:    c.Query("id_field"),
+		IDTemplate: c.Query("id_template"),
+		Delimiter:  ',',
+		Header:     c.Query("header") != "false",
+	}
+
+	if opts.Format == "" {
+		opts.Format = ImportNDJSON
+		if strings.Contains(c.ContentType(), "csv") {
+			opts.Format = ImportCSV
+		}
+	}
+	if opts.Format != ImportCSV && opts.Format != ImportNDJSON {
+		return opts, fmt.Errorf("unknown format [%s], valid formats are %s and %s", opts.Format, ImportCSV, ImportNDJSON)
+	}
+
+	switch delimiter := c.Query("delimiter"); delimiter {
+	case "":
+	case "tab", `\t`:
+		opts.Delimiter = '\t'
+	default:
+		r, size := utf8.DecodeRuneInString(delimiter)
+		if size != len(delimiter) || r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+			return opts, fmt.Errorf("invalid delimiter [%s], expected a single character", delimiter)
+		}
+		opts.Delimiter = r
+	}
+
+	if columns := c.Query("columns"); columns != "" {
+		opts.Columns = strings.Split(columns, ",")
+	}
+	if opts.Format == ImportCSV && !opts.Header && len(opts.Columns) == 0 {
+		return opts, fmt.Errorf("columns are required without a header row")
+	}
+
+	// types=age:integer,price:float
+	if types := c.Query("types"); types != "" {
+		opts.Types = make(map[string]string)
+		for _, hint := range strings.Split(types, ",") {
+			parts := strings.SplitN(hint, ":", 2)
+			if len(parts) != 2 || !zutil.SliceContains(core.ConvertTypes, parts[1]) {
+				return opts, fmt.Errorf("invalid type hint [%s], expected column:type with a type of %v", hint, core.ConvertTypes)
+			}
+			opts.Types[parts[0]] = parts[1]
+		}
+	}
+
+	return opts, nil
+}
+
+// ImportWorker indexes the documents of the body into the index. The documents go through the same pipelines,
+// mapping and batches as the documents of a bulk request. Only the documents that failed are reported in the items.
+// If the body cannot be read, the batches before the error are applied and the documents read since are dropped.
+func ImportWorker(target string, opts ImportOptions, body io.Reader) (*BulkResponse, error) {
+	start := time.Now()
+
+	read := &countingReader{Reader: body}
+	w := &bulkWriter{pipeline: opts.Pipeline, start: start, read: read}
+
+	resp := newBulkResponse()
+	// index indexes the document read at the line, or reports why it could not be read, with the id of what was read
+	index := func(line int, doc map[string]interface{}, size int, err error) error {
+		item := &BulkItem{Index: target, Line: line}
+		if err != nil {
+			item.ID = opts.readID(doc)
+			item.fail(http.StatusBadRequest, "mapper_parsing_exception", fmt.Sprintf("line %d: %v", line, err))
+			doc = nil
+		} else {
+			item.ID = opts.docID(doc)
+		}
+
+		done := func(item *BulkItem) { resp.add(bulkIndex, item, true) }
+		if err := w.add(bulkIndex, bulkMetadata{Index: target, ID: item.ID}, doc, v1.UpdateRequest{}, item, done); err != nil {
+			return err
+		}
+		return w.added(size)
+	}
+
+	var err error
+	if opts.Format == ImportCSV {
+		err = importCSV(read, opts, index)
+	} else {
+		err = importNDJSON(read, index)
+	}
+	if err == nil {
+		// Persist the batch to the index
+		err = w.flush()
+	}
+	if err != nil {
+		log.Print("Error importing documents: ", err.Error())
+		return nil, fmt.Errorf("%v, %d documents before it were imported", err, w.actions)
+	}
+	if w.flushes > 0 {
+		w.logProgress()
+	}
+
+	resp.Took = time.Since(start).Milliseconds()
+	return resp, nil
+}
+
+// docID returns the id of the document, or a new id if the options do not name one or it is empty.
+func (opts ImportOptions) docID(doc map[string]interface{}) string {
+	if id := opts.readID(doc); id != "" {
+		return id
+	}
+	return uuid.New().String()
+}
+
+// readID returns the id of the document named by the options, empty if they do not name one or it cannot be read.
+func (opts ImportOptions) readID(doc map[string]interface{}) string {
+	if doc == nil {
+		return ""
+	}
+	if opts.IDField != "" {
+		if v, ok := doc[opts.IDField]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+	} else if opts.IDTemplate != "" {
+		return core.ExpandTemplate(opts.IDTemplate, doc)
+	}
+	return ""
+}
+
+// importFunc indexes a document read at the line, or reports the error of the line. With an error, doc holds what
+// could be read of the document, if anything, to report its id.
+type importFunc func(line int, doc map[string]interface{}, size int, err error) error
+
+func importNDJSON(body io.Reader, index importFunc) error {
+	lines := newLineReader(body, getBulkLimits().maxLineBytes)
+	for {
+		line, err := lines.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil && !errors.Is(err, errLineTooLong) {
+			return err
+		}
+
+		var doc map[string]interface{}
+		if err == nil {
+			if line = bytes.TrimSpace(line); len(line) == 0 {
+				continue
+			}
+			if err = json.Unmarshal(line, &doc); err != nil {
+				err = fmt.Errorf("failed to parse document: %v", err)
+			}
+		}
+
+		if err := index(lines.number, doc, len(line), err); err != nil {
+			return err
+		}
+	}
+}
+
+// importCSV indexes every row as a document of the columns. Empty values are left out of the document.
+func importCSV(body io.Reader, opts ImportOptions, index importFunc) error {
+	r := csv.NewReader(bufio.NewReaderSize(body, 64*1024))
+	r.Comma = opts.Delimiter
+	r.FieldsPerRecord = -1 // rows with missing columns are accepted, rows with more columns are reported
+	r.ReuseRecord = true
+
+	columns := opts.Columns
+	if opts.Header {
+		header, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("header row: %v", err)
+		}
+		if len(columns) == 0 {
+			columns = make([]string, len(header))
+			for i, name := range header {
+				columns[i] = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")) // byte order mark of some exports
+			}
+		}
+	}
+
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+
+		var line, size int
+		var doc map[string]interface{}
+		var parseErr *csv.ParseError
+		switch {
+		case errors.As(err, &parseErr): // the row is reported, reading goes on with the next row
+			line = parseErr.StartLine
+			err = fmt.Errorf("position %d of line %d: %v", parseErr.Column, parseErr.Line, parseErr.Err)
+			if len(record) > 0 {
+				doc = rawDoc(columns, record)
+			}
+		case err != nil: // the body could not be read
+			return err
+		default:
+			line, _ = r.FieldPos(0)
+			doc, size, err = csvDoc(columns, record, opts.Types)
+		}
+
+		if err := index(line, doc, size, err); err != nil {
+			return err
+		}
+	}
+}
+
+// csvDoc builds the document of the row, converting the values of the columns with a type hint.
+func csvDoc(columns, record []string, types map[string]string) (map[string]interface{}, int, error) {
+	if len(record) > len(columns) {
+		return rawDoc(columns, record), 0, fmt.Errorf("%d values for %d columns", len(record), len(columns))
+	}
+
+	doc := make(map[string]interface{}, len(record))
+	size := 0
+	for i, value := range record {
+		size += len(value)
+		if value == "" || columns[i] == "" {
+			continue
+		}
+
+		typ, ok := types[columns[i]]
+		if !ok {
+			doc[columns[i]] = value
+			continue
+		}
+
+		v, err := core.ConvertValue(value, typ)
+		if err != nil {
+			return rawDoc(columns, record), size, fmt.Errorf("column [%s]: %v", columns[i], err)
+		}
+		doc[columns[i]] = v
+	}
+
+	return doc, size, nil
+}
+
+// rawDoc returns the values of the columns of the row unconverted, those of the extra values left out.
+func rawDoc(columns, record []string) map[string]interface{} {
+	doc := make(map[string]interface{}, len(record))
+	for i, value := range record {
+		if i < len(columns) && value != "" && columns[i] != "" {
+			doc[columns[i]] = value
+		}
+	}
+	return doc
+}
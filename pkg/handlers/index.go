@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,7 +11,6 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
-	"github.com/blugelabs/bluge"
 	v1 "github.com/prabhatsharma/zinc/pkg/meta/v1"
 	"github.com/prabhatsharma/zinc/pkg/zutil"
 
@@ -53,16 +53,97 @@ func SearchIndex(c *gin.Context) {
 	}
 }
 
+// OpenPointInTime opens a point in time on the index for the searches that page through it, kept open for the
+// keep_alive parameter after each search.
+func OpenPointInTime(c *gin.Context) {
+	name := c.Param("target")
+	index, ok := core.FindIndex(name)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "index '" + name + "' does not exist"})
+		return
+	}
+
+	id, err := index.OpenPointInTime(c.Query("keep_alive"))
+	if errors.Is(err, core.ErrTooManyPointsInTime) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": id})
+}
+
+// ClosePointInTime closes the point in time with the id of the request body.
+func ClosePointInTime(c *gin.Context) {
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := c.BindJSON(&req); err != nil || req.ID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the id of the point in time is required"})
+		return
+	}
+
+	if !core.ClosePointInTime(req.ID) {
+		c.JSON(http.StatusNotFound, gin.H{"succeeded": false, "num_freed": 0})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"succeeded": true, "num_freed": 1})
+}
+
+// ExplainDoc tells whether the document matches the query string of the q parameter, and how its score is computed.
+func ExplainDoc(c *gin.Context) {
+	name := c.Param("target")
+	docID := c.Param("id")
+	index, ok := core.FindIndex(name)
+	if !ok {
+		c.JSON(http.StatusNotFound, v1.ExplainResponse{Index: name, Type: name, ID: docID, Error: "index '" + name + "' does not exist"})
+		return
+	}
+
+	q := c.Query("q")
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the q parameter with the query is required"})
+		return
+	}
+
+	res, found, err := index.Explain(docID, q)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !found {
+		res.Error = "document '" + docID + "' does not exist"
+		c.JSON(http.StatusNotFound, res)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
 func CreateIndex(c *gin.Context) {
 	var newIndex core.Index
 	c.BindJSON(&newIndex)
 
+	if err := newIndex.Settings.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	index, err := core.NewIndex(newIndex.Name, newIndex.StorageType)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	if err := index.SetSettings(newIndex.Settings); err != nil {
+		index.Writer.Close()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	core.ZincIndexList[newIndex.Name] = index
 	c.JSON(http.StatusOK, gin.H{
 		"result":       "Index: " + newIndex.Name + " created",
@@ -81,7 +162,8 @@ func DeleteIndex(c *gin.Context) {
 		return
 	}
 
-	// 1. Close the index writer
+	// 1. Close the points in time and the index writer
+	index.ClosePointsInTime()
 	index.Writer.Close()
 
 	// 2. Delete from the cache
@@ -102,9 +184,11 @@ func DeleteIndex(c *gin.Context) {
 		}
 	}
 
-	// 4. Delete the index mapping
-	bdoc := bluge.NewDocument(indexName)
-	if err := core.ZincSystemIndexList[core.SystemIndexMapping].Writer.Delete(bdoc.ID()); err != nil {
+	// 4. Delete the index mapping and settings
+	if err := index.DeleteStoredSettings(); err != nil {
+		log.Print("failed to delete index settings: ", err.Error())
+	}
+	if err := index.DeleteStoredMapping(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 	} else {
 		c.JSON(http.StatusOK, gin.H{
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prabhatsharma/zinc/pkg/core"
+)
+
+// GetMapping returns the field types of the index.
+func GetMapping(c *gin.Context) {
+	name := c.Param("target")
+	index, ok := core.FindIndex(name)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "index '" + name + "' does not exist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{name: gin.H{"mappings": index.GetMappings()}})
+}
+
+// UpdateMapping declares the field types of the index. The index is created if it does not exist yet.
+func UpdateMapping(c *gin.Context) {
+	var mappings core.Mappings
+	if err := c.BindJSON(&mappings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	index, err := core.GetIndex(c.Param("target"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := index.PutMappings(mappings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok", "index": index.Name})
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prabhatsharma/zinc/pkg/core"
+	v1 "github.com/prabhatsharma/zinc/pkg/meta/v1"
+	"github.com/prabhatsharma/zinc/pkg/otlp"
+	"github.com/prabhatsharma/zinc/pkg/zutil"
+)
+
+// otlpSettings returns the settings of the OTLP receiver, read per request: the index template, expanded with the
+// attributes of the resource of each record, and the pipeline of the records.
+func otlpSettings() (index, pipeline string) {
+	return zutil.GetEnv("ZINC_OTLP_INDEX", "otel-logs-{{service.name}}"), zutil.GetEnv("ZINC_OTLP_PIPELINE", "")
+}
+
+// otlpInvalidIndexChars are the characters replaced in index names built from resource attributes.
+var otlpInvalidIndexChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// OTLPLogs indexes the log records of an OTLP/HTTP export request, in protobuf or JSON, into the index of their service.
+func OTLPLogs(c *gin.Context) {
+	contentType := c.ContentType()
+	if contentType != otlp.ContentTypeProtobuf && contentType != otlp.ContentTypeJSON {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "unsupported content type [" + contentType + "], use " +
+			otlp.ContentTypeProtobuf + " or " + otlp.ContentTypeJSON})
+		return
+	}
+
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req, err := otlp.Decode(contentType, data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid export request: " + err.Error()})
+		return
+	}
+
+	indexTemplate, pipeline := otlpSettings()
+
+	// Write the records index by index, as the writer is flushed whenever the index changes
+	var names []string
+	docsByIndex := make(map[string][]map[string]interface{})
+	for _, doc := range req.LogDocs(time.Now().UTC()) {
+		name := otlpIndexName(indexTemplate, doc.Resource)
+		if _, ok := docsByIndex[name]; !ok {
+			names = append(names, name)
+		}
+		docsByIndex[name] = append(docsByIndex[name], doc.Doc)
+	}
+
+	var rejected int64
+	var message string
+	w := &bulkWriter{pipeline: pipeline}
+	done := func(item *BulkItem) {
+		if item.Error != nil {
+			rejected++
+			message = item.Error.Reason
+		}
+	}
+	for _, name := range names {
+		for _, doc := range docsByIndex[name] {
+			item := &BulkItem{Index: name}
+			if err := w.add(bulkIndex, bulkMetadata{Index: name}, doc, v1.UpdateRequest{}, item, done); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+	}
+	if err := w.flush(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Data(http.StatusOK, contentType, otlp.EncodeResponse(contentType, rejected, message))
+}
+
+// otlpIndexName returns the index of the records of a resource from the index template. Resources without a service
+// name are unknown_service, as in the OpenTelemetry SDKs.
+func otlpIndexName(indexTemplate string, resource map[string]interface{}) string {
+	if _, ok := resource["service.name"]; !ok {
+		resource["service.name"] = "unknown_service"
+	}
+	name := otlpInvalidIndexChars.ReplaceAllString(core.ExpandTemplate(indexTemplate, resource), "_")
+	return strings.ToLower(name)
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prabhatsharma/zinc/pkg/core"
+)
+
+// ListPipelines returns all ingest pipelines.
+func ListPipelines(c *gin.Context) {
+	c.JSON(http.StatusOK, core.ListPipelines())
+}
+
+// GetPipeline returns the ingest pipeline with the name.
+func GetPipeline(c *gin.Context) {
+	name := c.Param("name")
+	p, ok := core.GetPipeline(name)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "pipeline '" + name + "' does not exist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
+
+// UpdatePipeline creates or replaces the ingest pipeline with the name.
+func UpdatePipeline(c *gin.Context) {
+	var p core.Pipeline
+	if err := c.BindJSON(&p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	p.Name = c.Param("name")
+	if err := core.SetPipeline(&p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok", "pipeline": p.Name})
+}
+
+// DeletePipeline deletes the ingest pipeline with the name.
+func DeletePipeline(c *gin.Context) {
+	name := c.Param("name")
+	if _, ok := core.GetPipeline(name); !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "pipeline '" + name + "' does not exist"})
+		return
+	}
+
+	if err := core.DeletePipeline(name); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Deleted", "pipeline": name})
+}
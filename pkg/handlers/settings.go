@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prabhatsharma/zinc/pkg/core"
+)
+
+// GetSettings returns the settings of the index.
+func GetSettings(c *gin.Context) {
+	name := c.Param("target")
+	index, ok := core.FindIndex(name)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "index '" + name + "' does not exist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{name: gin.H{"settings": index.Settings}})
+}
+
+// UpdateSettings updates the settings of the index. Settings not in the payload keep their current value.
+// The index is created if it does not exist yet.
+func UpdateSettings(c *gin.Context) {
+	index, err := core.GetIndex(c.Param("target"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings := index.Settings
+	if err := c.BindJSON(&settings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := settings.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := index.SetSettings(settings); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok", "index": index.Name})
+}
@@ -1,6 +1,8 @@
 package v1
 
 import (
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/blugelabs/bluge/search"
@@ -15,31 +17,242 @@ type ZincQuery struct {
 	Explain    bool           `json:"explain"`
 	Highlight  QueryHighlight `json:"highlight"`
 	Query      QueryParams    `json:"query"`
-	SortFields []string       `json:"sort_fields"`
+	// Bool is the query of the bool search type. start_time and end_time of Query still limit @timestamp.
+	Bool       *BoolQuery `json:"bool"`
+	SortFields []string   `json:"sort_fields"`
+	// Source filters the _source of the hits, Fields are the fields to return the values of from doc values and
+	// stored fields, with * wildcards.
+	Source SourceFilter `json:"_source"`
+	Fields []string     `json:"fields"`
+	// Aggs are the aggregations computed over all the matching documents, by name.
+	Aggs map[string]AggregationRequest `json:"aggs"`
+	// SearchAfter are the sort values of the last hit of the previous page, to return the hits that follow it
+	// instead of skipping From hits.
+	SearchAfter []string `json:"search_after"`
+	// PIT searches the point in time opened on the index instead of its latest documents.
+	PIT *PointInTime `json:"pit"`
+
+	// FieldTypes and FieldAnalyzers are the mapping of the index, filled in by the index.
+	FieldTypes     map[string]string `json:"-"`
+	FieldAnalyzers map[string]string `json:"-"`
+}
+
+// PointInTime refers to a point in time opened on an index, and keeps it open for KeepAlive more, e.g. 1m.
+type PointInTime struct {
+	ID        string `json:"id"`
+	KeepAlive string `json:"keep_alive"`
+}
+
+// AggregationRequest is an aggregation of a search, with one of the aggregation types.
+// The buckets of terms, range, histogram and date_histogram are aggregated further by the nested Aggs.
+type AggregationRequest struct {
+	Terms         *TermsAggregation         `json:"terms"`
+	Range         *RangeAggregation         `json:"range"`
+	Histogram     *HistogramAggregation     `json:"histogram"`
+	DateHistogram *DateHistogramAggregation `json:"date_histogram"`
+	Min           *MetricAggregation        `json:"min"`
+	Max           *MetricAggregation        `json:"max"`
+	Avg           *MetricAggregation        `json:"avg"`
+	Sum           *MetricAggregation        `json:"sum"`
+	Cardinality   *MetricAggregation        `json:"cardinality"`
+	Percentiles   *PercentilesAggregation   `json:"percentiles"`
+
+	Aggs map[string]AggregationRequest `json:"aggs"`
+}
+
+// TermsAggregation has a bucket for each of the most frequent values of the field.
+type TermsAggregation struct {
+	Field       string `json:"field"`
+	Size        int    `json:"size"`          // 10 if not given
+	MinDocCount int    `json:"min_doc_count"` // 1 if not given
+	// Order sorts the buckets by _count or _key, asc or desc, e.g. {"_key": "asc"}. The default is {"_count": "desc"}.
+	Order map[string]string `json:"order"`
+}
+
+// RangeAggregation has a bucket for each range of values of a numeric or date field.
+type RangeAggregation struct {
+	Field  string             `json:"field"`
+	Ranges []AggregationRange `json:"ranges"`
+}
+
+// AggregationRange is a range of values from From, inclusive, to To, exclusive. A range without From or To
+// is unbounded on that side. Bounds are numbers, or dates for date fields.
+type AggregationRange struct {
+	Key  string      `json:"key"`
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// HistogramAggregation has a bucket for each interval of values of a numeric field.
+type HistogramAggregation struct {
+	Field    string  `json:"field"`
+	Interval float64 `json:"interval"`
+	Offset   float64 `json:"offset"`
+	// MinDocCount leaves out the buckets with fewer documents. With 0, the default, empty buckets fill the gaps.
+	MinDocCount int `json:"min_doc_count"`
+}
+
+// DateHistogramAggregation has a bucket for each interval of time of a date field.
+type DateHistogramAggregation struct {
+	Field string `json:"field"` // @timestamp if not given
+	// Interval is a calendar interval: minute, hour, day, week, month, quarter, year or 1m, 1h, 1d, 1w, 1M, 1q, 1y,
+	// or a fixed interval like 30s, 5m, 12h or 7d.
+	Interval string `json:"interval"`
+	// TimeZone is where days and other calendar intervals start, e.g. Europe/Berlin or +02:00. UTC if not given.
+	TimeZone string `json:"time_zone"`
+	// MinDocCount leaves out the buckets with fewer documents. With 0, the default, empty buckets fill the gaps.
+	MinDocCount int `json:"min_doc_count"`
+}
+
+// MetricAggregation computes a metric of the values of a field.
+type MetricAggregation struct {
+	Field string `json:"field"`
+}
+
+// PercentilesAggregation estimates percentiles of the values of a numeric or date field.
+type PercentilesAggregation struct {
+	Field    string    `json:"field"`
+	Percents []float64 `json:"percents"` // 1, 5, 25, 50, 75, 95 and 99 if not given
+}
+
+// AggregationResponse is the result of an aggregation: the value of a metric, the values of percentiles, or buckets.
+type AggregationResponse struct {
+	// Value is left out if the metric has no value, e.g. the min of a field without values.
+	Value *float64 `json:"value,omitempty"`
+	// ValueAsString is the value of metrics of date fields as a date.
+	ValueAsString    string              `json:"value_as_string,omitempty"`
+	Values           map[string]float64  `json:"values,omitempty"`
+	Buckets          []AggregationBucket `json:"buckets,omitempty"`
+	SumOtherDocCount int                 `json:"sum_other_doc_count,omitempty"` // documents in terms not in buckets
+}
+
+// AggregationBucket is a bucket of documents with the results of the nested aggregations.
+type AggregationBucket struct {
+	// Key is the value of the bucket: a string or a number, the time in milliseconds since the epoch for dates,
+	// or the key of a range.
+	Key          interface{}                    `json:"key"`
+	KeyAsString  string                         `json:"key_as_string,omitempty"`
+	From         interface{}                    `json:"from,omitempty"`
+	To           interface{}                    `json:"to,omitempty"`
+	DocCount     uint64                         `json:"doc_count"`
+	Aggregations map[string]AggregationResponse `json:"aggregations,omitempty"`
+}
+
+// DSLQuery is a search request of the Elasticsearch compatible search API, in the Elasticsearch query DSL.
+type DSLQuery struct {
+	// Query is the query DSL object, e.g. {"match": {"message": "error"}}. All documents match if it is empty.
+	Query map[string]interface{} `json:"query"`
+	From  int                    `json:"from"`
+	Size  *int                   `json:"size"` // 10 if not given
+	// Sort is a field name, an object like {"@timestamp": {"order": "desc"}}, or a list of them.
+	Sort interface{} `json:"sort"`
+
+	// FieldTypes and FieldAnalyzers are the mapping of the index, filled in by the index.
+	FieldTypes     map[string]string `json:"-"`
+	FieldAnalyzers map[string]string `json:"-"`
+}
+
+// BoolQuery combines clauses like the bool query of Elasticsearch. Filter clauses are matched like must clauses.
+type BoolQuery struct {
+	Must    []QueryClause `json:"must"`
+	Should  []QueryClause `json:"should"`
+	MustNot []QueryClause `json:"must_not"`
+	Filter  []QueryClause `json:"filter"`
+	// MinimumShouldMatch is the number of should clauses to match: a count like 2, a negative count like -1
+	// for all but one, or a percentage like "50%". With no must or filter clause, one should clause must match.
+	MinimumShouldMatch interface{} `json:"minimum_should_match"`
+}
+
+// QueryClause is a clause of a bool query: a query of one of the search types, or a nested bool query.
+type QueryClause struct {
+	SearchType string      `json:"search_type"`
+	Query      QueryParams `json:"query"`
+	Bool       *BoolQuery  `json:"bool"`
 }
 
 type QueryParams struct {
-	Boost     int        `json:"boost"`
-	Term      string     `json:"term"`
-	Terms     [][]string `json:"terms"` // For multi phrase query
-	Field     string     `json:"field"`
-	StartTime time.Time  `json:"start_time"`
-	EndTime   time.Time  `json:"end_time"`
+	Boost    int        `json:"boost"`
+	Term     string     `json:"term"`
+	Terms    [][]string `json:"terms"` // For multi phrase query
+	Field    string     `json:"field"`
+	Analyzer string     `json:"analyzer"` // Overrides the analyzer mapped for the field
+	// StartTime and EndTime limit the time field, @timestamp by default, to [start_time, end_time). They are dates,
+	// date math like now-15m or now/d, or ISO 8601 durations like PT15M before now, resolved in TimeZone.
+	// Either can be left out for an open range, without both the time is not limited.
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	TimeField string `json:"time_field"`
+	TimeZone  string `json:"time_zone"` // a name like Europe/Paris or an offset like +01:00, by default ZINC_TIME_ZONE or UTC
 }
 
+// SourceFilter selects the fields of the _source of the hits. It is false to leave out the _source, a list of
+// the fields to include, or an object with includes and excludes. Patterns can have * wildcards.
+type SourceFilter struct {
+	Disabled bool     `json:"-"`
+	Includes []string `json:"includes"`
+	Excludes []string `json:"excludes"`
+}
+
+func (f *SourceFilter) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case nil:
+		*f = SourceFilter{}
+		return nil
+	case bool:
+		*f = SourceFilter{Disabled: !v}
+		return nil
+	case string:
+		*f = SourceFilter{Includes: []string{v}}
+		return nil
+	case []interface{}:
+		var includes []string
+		if err := json.Unmarshal(data, &includes); err != nil {
+			return fmt.Errorf("_source must be a list of fields: %v", err)
+		}
+		*f = SourceFilter{Includes: includes}
+		return nil
+	case map[string]interface{}:
+		type sourceFilter SourceFilter // without the UnmarshalJSON method
+		var filter sourceFilter
+		if err := json.Unmarshal(data, &filter); err != nil {
+			return fmt.Errorf("_source must have lists of includes and excludes: %v", err)
+		}
+		*f = SourceFilter(filter)
+		return nil
+	}
+
+	return fmt.Errorf("_source must be a boolean, a list of fields or an object with includes and excludes")
+}
+
+// QueryHighlight are the fields to highlight the matching terms of in the hits, with * wildcards, and how.
 type QueryHighlight struct {
 	Fields []string `json:"fields"`
-	Style  string   `json:"style"`
+	Style  string   `json:"style"` // html (default) or ansi
+	// PreTag and PostTag surround the terms in the html style, <mark> and </mark> by default.
+	PreTag  string `json:"pre_tag"`
+	PostTag string `json:"post_tag"`
+	// FragmentSize is the length of the fragments in characters, 100 by default. NumberOfFragments is the
+	// most fragments of a field, 5 by default. With 0 the whole value of the field is highlighted.
+	FragmentSize      *int `json:"fragment_size"`
+	NumberOfFragments *int `json:"number_of_fragments"`
 }
 
 // SearchResponse for a query
 type SearchResponse struct {
-	Took     int              `json:"took"` // Time it took to generate the response
-	TimedOut bool             `json:"timed_out"`
-	MaxScore float64          `json:"max_score"`
-	Hits     Hits             `json:"hits"`
-	Buckets  []*search.Bucket `json:"buckets"`
-	Error    string           `json:"error"`
+	Took     int     `json:"took"` // Time it took to generate the response
+	TimedOut bool    `json:"timed_out"`
+	MaxScore float64 `json:"max_score"`
+	Hits     Hits    `json:"hits"`
+	// Aggregations are the results of the aggregations of the query, by name.
+	Aggregations map[string]AggregationResponse `json:"aggregations,omitempty"`
+	// PitID is the id of the point in time the search used.
+	PitID string `json:"pit_id,omitempty"`
+	Error string `json:"error"`
 }
 
 type Hits struct {
@@ -52,8 +265,64 @@ type Hit struct {
 	Type      string      `json:"_type"`
 	ID        string      `json:"_id"`
 	Score     float64     `json:"_score"`
+	Version   int64       `json:"_version,omitempty"`
+	SeqNo     int64       `json:"_seq_no,omitempty"`
 	Timestamp time.Time   `json:"@timestamp"`
-	Source    interface{} `json:"_source"`
+	Source    interface{} `json:"_source,omitempty"`
+	// Fields are the values of the fields the search asked for.
+	Fields map[string][]interface{} `json:"fields,omitempty"`
+	// Highlight are the highlighted fragments of the fields, if the search asked for them.
+	Highlight map[string][]string `json:"highlight,omitempty"`
+	// Explanation tells how the score was computed, if the search asked for it with explain.
+	Explanation *search.Explanation `json:"_explanation,omitempty"`
+	// Sort are the sort values of the hit, opaque strings to pass as search_after for the next page.
+	Sort []string `json:"sort,omitempty"`
+}
+
+// ExplainResponse tells whether and why a document matches a query.
+type ExplainResponse struct {
+	Index       string              `json:"_index"`
+	Type        string              `json:"_type"`
+	ID          string              `json:"_id"`
+	Matched     bool                `json:"matched"`
+	Explanation *search.Explanation `json:"explanation,omitempty"`
+	Error       string              `json:"error,omitempty"`
+}
+
+// Doc is a document fetched by its id
+type Doc struct {
+	Index     string      `json:"_index"`
+	Type      string      `json:"_type"`
+	ID        string      `json:"_id"`
+	Found     bool        `json:"found"`
+	Version   int64       `json:"_version,omitempty"`
+	SeqNo     int64       `json:"_seq_no,omitempty"`
+	Timestamp *time.Time  `json:"@timestamp,omitempty"`
+	Source    interface{} `json:"_source,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+// MultiGetRequest lists the documents to fetch with the _mget API.
+// Docs without an index and ids are fetched from the index in the path.
+type MultiGetRequest struct {
+	Docs []DocRef `json:"docs"`
+	IDs  []string `json:"ids"`
+}
+
+// UpdateRequest is a partial update of a document with the _update API.
+type UpdateRequest struct {
+	// Doc is deep merged into the stored document
+	Doc map[string]interface{} `json:"doc"`
+	// Upsert is indexed as the document if it does not exist
+	Upsert map[string]interface{} `json:"upsert"`
+	// DocAsUpsert indexes Doc as the document if it does not exist
+	DocAsUpsert bool `json:"doc_as_upsert"`
+}
+
+// DocRef refers to a document by its index and id
+type DocRef struct {
+	Index string `json:"_index"`
+	ID    string `json:"_id"`
 }
 
 type Total struct {
@@ -0,0 +1,269 @@
+package middleware
+
+import (
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/prabhatsharma/zinc/pkg/zutil"
+)
+
+// Content encodings of request and response bodies.
+const (
+	encodingGzip    = "gzip"
+	encodingXGzip   = "x-gzip"
+	encodingDeflate = "deflate"
+	encodingZstd    = "zstd"
+)
+
+// maxDecompressedBodyMB returns the max size in MB of a decompressed request body, so a small compressed body cannot
+// expand without end.
+func maxDecompressedBodyMB() int64 {
+	return int64(zutil.GetEnvInt("ZINC_MAX_DECOMPRESSED_BODY_MB", 1024))
+}
+
+// ErrBodyTooLarge is returned by the reader of a decompressed request body larger than the limit.
+var ErrBodyTooLarge = errors.New("decompressed request body is too large")
+
+// Decompress decompresses the request body according to its Content-Encoding: gzip, deflate or zstd.
+// The handlers read the plain body, up to maxDecompressedBodyMB.
+func Decompress(c *gin.Context) {
+	contentEncoding := strings.ToLower(strings.TrimSpace(c.GetHeader("Content-Encoding")))
+	if contentEncoding == "" || contentEncoding == "identity" {
+		c.Next()
+		return
+	}
+
+	// The encodings are listed in the order they were applied, so they are undone from the last
+	encodings := strings.Split(contentEncoding, ",")
+	body := c.Request.Body
+	var decoders []io.Closer
+	defer func() {
+		for _, d := range decoders {
+			d.Close()
+		}
+	}()
+
+	for i := len(encodings) - 1; i >= 0; i-- {
+		decoder, err := newDecoder(strings.TrimSpace(encodings[i]), body)
+		if errors.Is(err, errUnsupportedEncoding) {
+			c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid " + encodings[i] + " request body: " + err.Error()})
+			return
+		}
+		decoders = append(decoders, decoder)
+		body = decoder
+	}
+
+	limitMB := maxDecompressedBodyMB()
+	c.Request.Body = &limitedBody{ReadCloser: body, remaining: limitMB << 20, limitMB: limitMB}
+	c.Request.Header.Del("Content-Encoding")
+	c.Request.Header.Del("Content-Length")
+	c.Request.ContentLength = -1
+
+	c.Next()
+}
+
+var errUnsupportedEncoding = errors.New("unsupported Content-Encoding")
+
+// newDecoder returns the reader of the body decoded from the encoding. Closing it does not close the body.
+func newDecoder(encoding string, body io.Reader) (io.ReadCloser, error) {
+	switch encoding {
+	case "identity":
+		return io.NopCloser(body), nil
+	case encodingGzip, encodingXGzip:
+		return gzip.NewReader(body)
+	case encodingDeflate: // zlib format, see RFC 9110
+		return zlib.NewReader(body)
+	case encodingZstd:
+		d, err := zstd.NewReader(body, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	}
+
+	return nil, fmt.Errorf("%w [%s], supported encodings are %s, %s and %s", errUnsupportedEncoding, encoding,
+		encodingGzip, encodingDeflate, encodingZstd)
+}
+
+// limitedBody fails with ErrBodyTooLarge once more than remaining bytes are read.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	limitMB   int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, b.tooLarge()
+	}
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n, b.tooLarge()
+	}
+	return n, err
+}
+
+func (b *limitedBody) tooLarge() error {
+	return fmt.Errorf("%w, it is larger than %d MB, the limit set by ZINC_MAX_DECOMPRESSED_BODY_MB", ErrBodyTooLarge, b.limitMB)
+}
+
+// Compress compresses the response body with gzip or zstd if the client accepts it. gzip is preferred.
+func Compress(c *gin.Context) {
+	encoding := acceptedEncoding(c.GetHeader("Accept-Encoding"))
+	if encoding == "" || c.Request.Method == http.MethodHead {
+		c.Next()
+		return
+	}
+
+	w := &compressWriter{ResponseWriter: c.Writer, encoding: encoding}
+	c.Writer = w
+	defer w.close()
+
+	c.Next()
+}
+
+// acceptedEncoding returns the encoding to compress the response with, or an empty string if the client accepts none.
+func acceptedEncoding(acceptEncoding string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if len(fields) > 1 && strings.ReplaceAll(strings.TrimSpace(fields[1]), " ", "") == "q=0" {
+			continue // explicitly not acceptable
+		}
+		accepted[name] = true
+	}
+
+	switch {
+	case accepted[encodingGzip] || accepted[encodingXGzip]:
+		return encodingGzip
+	case accepted[encodingZstd]:
+		return encodingZstd
+	}
+
+	return ""
+}
+
+var (
+	gzipWriters = sync.Pool{New: func() interface{} { return gzip.NewWriter(io.Discard) }}
+	zstdWriters = sync.Pool{New: func() interface{} {
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return w
+	}}
+)
+
+// compressWriter compresses the response body. Whether to compress is decided on the first write,
+// once the handler has set the status and the headers.
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	started  bool
+	gzip     *gzip.Writer
+	zstd     *zstd.Encoder
+}
+
+func (w *compressWriter) start() {
+	w.started = true
+
+	h := w.Header()
+	status := w.Status()
+	if h.Get("Content-Encoding") != "" || status == http.StatusNoContent || status == http.StatusNotModified {
+		return
+	}
+	// the ranges of a partial response are ranges of the uncompressed body
+	if status == http.StatusPartialContent || h.Get("Content-Range") != "" || compressedContentType(h.Get("Content-Type")) {
+		return
+	}
+
+	h.Set("Content-Encoding", w.encoding)
+	h.Add("Vary", "Accept-Encoding")
+	h.Del("Content-Length")
+
+	if w.encoding == encodingZstd {
+		w.zstd = zstdWriters.Get().(*zstd.Encoder)
+		w.zstd.Reset(w.ResponseWriter)
+	} else {
+		w.gzip = gzipWriters.Get().(*gzip.Writer)
+		w.gzip.Reset(w.ResponseWriter)
+	}
+}
+
+// compressedContentTypes are the content types of bodies that are already compressed, or match a prefix of them.
+var compressedContentTypes = []string{
+	"image/", "audio/", "video/", "font/woff",
+	"application/gzip", "application/x-gzip", "application/zip", "application/zstd",
+}
+
+// compressedContentType reports whether a body of the content type is already compressed. SVG images are text.
+func compressedContentType(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	if strings.HasPrefix(contentType, "image/svg") {
+		return false
+	}
+	for _, prefix := range compressedContentTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.started {
+		w.start()
+	}
+
+	switch {
+	case w.gzip != nil:
+		return w.gzip.Write(b)
+	case w.zstd != nil:
+		return w.zstd.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush sends the data compressed so far to the client.
+func (w *compressWriter) Flush() {
+	switch {
+	case w.gzip != nil:
+		w.gzip.Flush()
+	case w.zstd != nil:
+		w.zstd.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// close writes the end of the compressed body and returns the compressor to its pool.
+func (w *compressWriter) close() {
+	switch {
+	case w.gzip != nil:
+		w.gzip.Close()
+		w.gzip.Reset(io.Discard)
+		gzipWriters.Put(w.gzip)
+	case w.zstd != nil:
+		w.zstd.Close()
+		w.zstd.Reset(nil)
+		zstdWriters.Put(w.zstd)
+	}
+}
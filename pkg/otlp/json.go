@@ -0,0 +1,103 @@
+package otlp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// The JSON encoding of OTLP follows the protobuf JSON mapping, except that trace and span ids are hex strings.
+// 64 bit integers may be strings or numbers.
+
+// uint64Value is a 64 bit unsigned integer, given as a string or a number in JSON.
+type uint64Value uint64
+
+func (v *uint64Value) UnmarshalJSON(data []byte) error {
+	n, err := parseJSONInt(data)
+	if err != nil {
+		return err
+	}
+	*v = uint64Value(n)
+	return nil
+}
+
+func parseJSONInt(data []byte) (int64, error) {
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return 0, err
+	}
+	if number == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(string(number), 10, 64)
+	if err != nil {
+		if u, uerr := strconv.ParseUint(string(number), 10, 64); uerr == nil {
+			return int64(u), nil
+		}
+		return 0, fmt.Errorf("invalid integer %s", data)
+	}
+	return n, nil
+}
+
+// anyValueJSON is an AnyValue in JSON, where the field that is set gives the type of the value.
+type anyValueJSON struct {
+	StringValue *string          `json:"stringValue"`
+	BoolValue   *bool            `json:"boolValue"`
+	IntValue    *json.RawMessage `json:"intValue"`
+	DoubleValue *float64         `json:"doubleValue"`
+	ArrayValue  *struct {
+		Values []AnyValue `json:"values"`
+	} `json:"arrayValue"`
+	KvlistValue *struct {
+		Values []KeyValue `json:"values"`
+	} `json:"kvlistValue"`
+	BytesValue *string `json:"bytesValue"`
+}
+
+func (v *AnyValue) UnmarshalJSON(data []byte) error {
+	var j anyValueJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	switch {
+	case j.StringValue != nil:
+		v.Value = *j.StringValue
+	case j.BoolValue != nil:
+		v.Value = *j.BoolValue
+	case j.IntValue != nil:
+		n, err := parseJSONInt(*j.IntValue)
+		if err != nil {
+			return err
+		}
+		v.Value = float64(n)
+	case j.DoubleValue != nil:
+		v.Value = *j.DoubleValue
+	case j.ArrayValue != nil:
+		values := make([]interface{}, len(j.ArrayValue.Values))
+		for i, value := range j.ArrayValue.Values {
+			values[i] = value.Value
+		}
+		v.Value = values
+	case j.KvlistValue != nil:
+		v.Value = attributeMap(j.KvlistValue.Values)
+	case j.BytesValue != nil:
+		if _, err := base64.StdEncoding.DecodeString(*j.BytesValue); err != nil {
+			return fmt.Errorf("invalid bytesValue: %v", err)
+		}
+		v.Value = *j.BytesValue
+	default:
+		v.Value = nil
+	}
+
+	return nil
+}
+
+func decodeJSON(data []byte) (*ExportLogsRequest, error) {
+	var req ExportLogsRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
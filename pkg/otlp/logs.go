@@ -0,0 +1,171 @@
+// Package otlp decodes the log export requests of the OpenTelemetry protocol, OTLP/HTTP, into documents.
+package otlp
+
+import (
+	"time"
+)
+
+// Content types of OTLP/HTTP requests and responses.
+const (
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeJSON     = "application/json"
+)
+
+// ExportLogsRequest is the body of a log export request, ExportLogsServiceRequest in the OTLP protobuf definitions.
+type ExportLogsRequest struct {
+	ResourceLogs []ResourceLogs `json:"resourceLogs"`
+}
+
+// ResourceLogs are the logs of a resource, e.g. a service.
+type ResourceLogs struct {
+	Resource  Resource    `json:"resource"`
+	ScopeLogs []ScopeLogs `json:"scopeLogs"`
+	// InstrumentationLibraryLogs are the ScopeLogs of senders older than OTLP 0.15.
+	InstrumentationLibraryLogs []ScopeLogs `json:"instrumentationLibraryLogs"`
+}
+
+// Resource describes the source of the logs with attributes like service.name.
+type Resource struct {
+	Attributes []KeyValue `json:"attributes"`
+}
+
+// ScopeLogs are the logs of an instrumentation scope, e.g. a logging library.
+type ScopeLogs struct {
+	Scope      Scope       `json:"scope"`
+	LogRecords []LogRecord `json:"logRecords"`
+	// InstrumentationLibrary is the Scope of senders older than OTLP 0.15.
+	InstrumentationLibrary Scope `json:"instrumentationLibrary"`
+}
+
+// Scope is the instrumentation scope of logs.
+type Scope struct {
+	Name       string     `json:"name"`
+	Version    string     `json:"version"`
+	Attributes []KeyValue `json:"attributes"`
+}
+
+// LogRecord is a log entry.
+type LogRecord struct {
+	TimeUnixNano         uint64Value `json:"timeUnixNano"`
+	ObservedTimeUnixNano uint64Value `json:"observedTimeUnixNano"`
+	SeverityNumber       int32       `json:"severityNumber"`
+	SeverityText         string      `json:"severityText"`
+	Body                 AnyValue    `json:"body"`
+	Attributes           []KeyValue  `json:"attributes"`
+	Flags                uint32      `json:"flags"`
+	TraceID              string      `json:"traceId"` // hex encoded
+	SpanID               string      `json:"spanId"`  // hex encoded
+	EventName            string      `json:"eventName"`
+}
+
+// KeyValue is an attribute.
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+// AnyValue is the value of an attribute or of the body of a log record: a string, a bool, a float64,
+// a []interface{}, a map[string]interface{}, nil, or bytes as a base64 string.
+type AnyValue struct {
+	Value interface{}
+}
+
+// LogDoc is the document of a log record with the attributes of its resource.
+type LogDoc struct {
+	// Resource maps the keys of the attributes of the resource to their values.
+	Resource map[string]interface{}
+	Doc      map[string]interface{}
+}
+
+// severityTexts are the names of the ranges of severity numbers, 1 to 4 is TRACE, 5 to 8 is DEBUG and so on.
+var severityTexts = []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+// LogDocs returns the documents of the log records of the request, with the fields @timestamp, observed_timestamp,
+// severity_number, severity_text, body, attributes, resource, scope, trace_id, span_id, flags and event_name.
+// Records without a time get the time they were observed, or else now.
+func (req *ExportLogsRequest) LogDocs(now time.Time) []LogDoc {
+	var docs []LogDoc
+	for _, rl := range req.ResourceLogs {
+		resource := attributeMap(rl.Resource.Attributes)
+		for _, scopeLogs := range [][]ScopeLogs{rl.ScopeLogs, rl.InstrumentationLibraryLogs} {
+			for _, sl := range scopeLogs {
+				scope := sl.Scope
+				if scope.Name == "" {
+					scope = sl.InstrumentationLibrary
+				}
+				for i := range sl.LogRecords {
+					docs = append(docs, LogDoc{Resource: resource, Doc: sl.LogRecords[i].doc(rl.Resource, scope, now)})
+				}
+			}
+		}
+	}
+
+	return docs
+}
+
+// doc returns the document of the record. Every document gets its own maps, as pipelines may change them.
+func (r *LogRecord) doc(resource Resource, scope Scope, now time.Time) map[string]interface{} {
+	timestamp := now
+	switch {
+	case r.TimeUnixNano > 0:
+		timestamp = time.Unix(0, int64(r.TimeUnixNano)).UTC()
+	case r.ObservedTimeUnixNano > 0:
+		timestamp = time.Unix(0, int64(r.ObservedTimeUnixNano)).UTC()
+	}
+
+	doc := map[string]interface{}{"@timestamp": timestamp.Format(time.RFC3339Nano)}
+	if r.ObservedTimeUnixNano > 0 {
+		doc["observed_timestamp"] = time.Unix(0, int64(r.ObservedTimeUnixNano)).UTC().Format(time.RFC3339Nano)
+	}
+	if r.SeverityNumber > 0 {
+		doc["severity_number"] = float64(r.SeverityNumber)
+	}
+	switch {
+	case r.SeverityText != "":
+		doc["severity_text"] = r.SeverityText
+	case r.SeverityNumber > 0 && int(r.SeverityNumber-1)/4 < len(severityTexts):
+		doc["severity_text"] = severityTexts[(r.SeverityNumber-1)/4]
+	}
+	if r.Body.Value != nil {
+		doc["body"] = r.Body.Value
+	}
+	if len(r.Attributes) > 0 {
+		doc["attributes"] = attributeMap(r.Attributes)
+	}
+	if len(resource.Attributes) > 0 {
+		doc["resource"] = attributeMap(resource.Attributes)
+	}
+	if scope.Name != "" {
+		s := map[string]interface{}{"name": scope.Name}
+		if scope.Version != "" {
+			s["version"] = scope.Version
+		}
+		if len(scope.Attributes) > 0 {
+			s["attributes"] = attributeMap(scope.Attributes)
+		}
+		doc["scope"] = s
+	}
+	if r.TraceID != "" {
+		doc["trace_id"] = r.TraceID
+	}
+	if r.SpanID != "" {
+		doc["span_id"] = r.SpanID
+	}
+	if r.Flags != 0 {
+		doc["flags"] = float64(r.Flags)
+	}
+	if r.EventName != "" {
+		doc["event_name"] = r.EventName
+	}
+
+	return doc
+}
+
+// attributeMap returns the values of the attributes by key.
+func attributeMap(attributes []KeyValue) map[string]interface{} {
+	m := make(map[string]interface{}, len(attributes))
+	for _, kv := range attributes {
+		m[kv.Key] = kv.Value.Value
+	}
+	return m
+}
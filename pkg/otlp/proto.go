@@ -0,0 +1,270 @@
+package otlp
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The protobuf messages are decoded by field number, see opentelemetry/proto/logs/v1/logs.proto and
+// opentelemetry/proto/common/v1/common.proto. Unknown fields are skipped.
+
+var errInvalidProtobuf = errors.New("invalid protobuf message")
+
+// eachField calls fn with the number, the type and the value of every field of the message. The value of
+// a varint, fixed32 or fixed64 field is in n, the value of a length delimited field in b.
+func eachField(data []byte, fn func(num protowire.Number, typ protowire.Type, n uint64, b []byte) error) error {
+	for len(data) > 0 {
+		num, typ, l := protowire.ConsumeTag(data)
+		if l < 0 {
+			return errInvalidProtobuf
+		}
+		data = data[l:]
+
+		var n uint64
+		var b []byte
+		switch typ {
+		case protowire.VarintType:
+			n, l = protowire.ConsumeVarint(data)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, l = protowire.ConsumeFixed32(data)
+			n = uint64(v)
+		case protowire.Fixed64Type:
+			n, l = protowire.ConsumeFixed64(data)
+		case protowire.BytesType:
+			b, l = protowire.ConsumeBytes(data)
+		default:
+			l = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if l < 0 {
+			return errInvalidProtobuf
+		}
+		data = data[l:]
+
+		if err := fn(num, typ, n, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeProtobuf(data []byte) (*ExportLogsRequest, error) {
+	req := &ExportLogsRequest{}
+	err := eachField(data, func(num protowire.Number, typ protowire.Type, n uint64, b []byte) error {
+		if num == 1 && typ == protowire.BytesType {
+			var rl ResourceLogs
+			if err := rl.decode(b); err != nil {
+				return err
+			}
+			req.ResourceLogs = append(req.ResourceLogs, rl)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func (rl *ResourceLogs) decode(data []byte) error {
+	return eachField(data, func(num protowire.Number, typ protowire.Type, n uint64, b []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 1:
+			return eachField(b, func(num protowire.Number, typ protowire.Type, n uint64, b []byte) error {
+				return appendKeyValue(&rl.Resource.Attributes, num == 1 && typ == protowire.BytesType, b)
+			})
+		case 2, 1000:
+			var sl ScopeLogs
+			if err := sl.decode(b); err != nil {
+				return err
+			}
+			if num == 2 {
+				rl.ScopeLogs = append(rl.ScopeLogs, sl)
+			} else {
+				rl.InstrumentationLibraryLogs = append(rl.InstrumentationLibraryLogs, sl)
+			}
+		}
+		return nil
+	})
+}
+
+func (sl *ScopeLogs) decode(data []byte) error {
+	return eachField(data, func(num protowire.Number, typ protowire.Type, n uint64, b []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 1:
+			return sl.Scope.decode(b)
+		case 2:
+			var r LogRecord
+			if err := r.decode(b); err != nil {
+				return err
+			}
+			sl.LogRecords = append(sl.LogRecords, r)
+		}
+		return nil
+	})
+}
+
+func (s *Scope) decode(data []byte) error {
+	return eachField(data, func(num protowire.Number, typ protowire.Type, n uint64, b []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 1:
+			s.Name = string(b)
+		case 2:
+			s.Version = string(b)
+		case 3:
+			return appendKeyValue(&s.Attributes, true, b)
+		}
+		return nil
+	})
+}
+
+func (r *LogRecord) decode(data []byte) error {
+	return eachField(data, func(num protowire.Number, typ protowire.Type, n uint64, b []byte) error {
+		switch {
+		case num == 1 && typ == protowire.Fixed64Type:
+			r.TimeUnixNano = uint64Value(n)
+		case num == 2 && typ == protowire.VarintType:
+			r.SeverityNumber = int32(n)
+		case num == 3 && typ == protowire.BytesType:
+			r.SeverityText = string(b)
+		case num == 5 && typ == protowire.BytesType:
+			return r.Body.decode(b)
+		case num == 6 && typ == protowire.BytesType:
+			return appendKeyValue(&r.Attributes, true, b)
+		case num == 8 && typ == protowire.Fixed32Type:
+			r.Flags = uint32(n)
+		case num == 9 && typ == protowire.BytesType:
+			r.TraceID = hex.EncodeToString(b)
+		case num == 10 && typ == protowire.BytesType:
+			r.SpanID = hex.EncodeToString(b)
+		case num == 11 && typ == protowire.Fixed64Type:
+			r.ObservedTimeUnixNano = uint64Value(n)
+		case num == 12 && typ == protowire.BytesType:
+			r.EventName = string(b)
+		}
+		return nil
+	})
+}
+
+// appendKeyValue decodes the KeyValue message in data and appends it to the attributes if ok.
+func appendKeyValue(attributes *[]KeyValue, ok bool, data []byte) error {
+	if !ok {
+		return nil
+	}
+	var kv KeyValue
+	err := eachField(data, func(num protowire.Number, typ protowire.Type, n uint64, b []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 1:
+			kv.Key = string(b)
+		case 2:
+			return kv.Value.decode(b)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	*attributes = append(*attributes, kv)
+	return nil
+}
+
+func (v *AnyValue) decode(data []byte) error {
+	return eachField(data, func(num protowire.Number, typ protowire.Type, n uint64, b []byte) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			v.Value = string(b)
+		case num == 2 && typ == protowire.VarintType:
+			v.Value = n != 0
+		case num == 3 && typ == protowire.VarintType:
+			v.Value = float64(int64(n))
+		case num == 4 && typ == protowire.Fixed64Type:
+			v.Value = math.Float64frombits(n)
+		case num == 5 && typ == protowire.BytesType:
+			values := []interface{}{}
+			err := eachField(b, func(num protowire.Number, typ protowire.Type, n uint64, b []byte) error {
+				if num != 1 || typ != protowire.BytesType {
+					return nil
+				}
+				var value AnyValue
+				if err := value.decode(b); err != nil {
+					return err
+				}
+				values = append(values, value.Value)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			v.Value = values
+		case num == 6 && typ == protowire.BytesType:
+			var values []KeyValue
+			err := eachField(b, func(num protowire.Number, typ protowire.Type, n uint64, b []byte) error {
+				return appendKeyValue(&values, num == 1 && typ == protowire.BytesType, b)
+			})
+			if err != nil {
+				return err
+			}
+			v.Value = attributeMap(values)
+		case num == 7 && typ == protowire.BytesType:
+			v.Value = base64.StdEncoding.EncodeToString(b)
+		}
+		return nil
+	})
+}
+
+// Decode decodes an export request in the given content type, protobuf or JSON.
+func Decode(contentType string, data []byte) (*ExportLogsRequest, error) {
+	switch contentType {
+	case ContentTypeProtobuf:
+		return decodeProtobuf(data)
+	case ContentTypeJSON:
+		return decodeJSON(data)
+	}
+	return nil, fmt.Errorf("unsupported content type %q, use %s or %s", contentType, ContentTypeProtobuf, ContentTypeJSON)
+}
+
+// EncodeResponse returns the ExportLogsServiceResponse in the given content type. If rejected is not zero,
+// the response reports a partial success with the number of rejected records and the error message.
+func EncodeResponse(contentType string, rejected int64, message string) []byte {
+	if contentType == ContentTypeJSON {
+		if rejected == 0 {
+			return []byte("{}")
+		}
+		b, _ := json.Marshal(map[string]interface{}{
+			"partialSuccess": map[string]string{"rejectedLogRecords": strconv.FormatInt(rejected, 10), "errorMessage": message},
+		})
+		return b
+	}
+
+	if rejected == 0 {
+		return []byte{}
+	}
+	var partial []byte
+	partial = protowire.AppendTag(partial, 1, protowire.VarintType)
+	partial = protowire.AppendVarint(partial, uint64(rejected))
+	partial = protowire.AppendTag(partial, 2, protowire.BytesType)
+	partial = protowire.AppendString(partial, message)
+
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	return protowire.AppendBytes(b, partial)
+}
@@ -0,0 +1,183 @@
+package otlp
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func message(fields ...[]byte) []byte {
+	var b []byte
+	for _, f := range fields {
+		b = append(b, f...)
+	}
+	return b
+}
+
+func bytesField(num protowire.Number, fields ...[]byte) []byte {
+	b := protowire.AppendTag(nil, num, protowire.BytesType)
+	return protowire.AppendBytes(b, message(fields...))
+}
+
+func stringField(num protowire.Number, s string) []byte {
+	return bytesField(num, []byte(s))
+}
+
+func varintField(num protowire.Number, n uint64) []byte {
+	return protowire.AppendVarint(protowire.AppendTag(nil, num, protowire.VarintType), n)
+}
+
+func fixed32Field(num protowire.Number, n uint32) []byte {
+	return protowire.AppendFixed32(protowire.AppendTag(nil, num, protowire.Fixed32Type), n)
+}
+
+func fixed64Field(num protowire.Number, n uint64) []byte {
+	return protowire.AppendFixed64(protowire.AppendTag(nil, num, protowire.Fixed64Type), n)
+}
+
+func keyValue(num protowire.Number, key string, value []byte) []byte {
+	return bytesField(num, stringField(1, key), bytesField(2, value))
+}
+
+func TestAnyValueDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want interface{}
+	}{
+		{"string", stringField(1, "hello"), "hello"},
+		{"bool", varintField(2, 1), true},
+		{"false", varintField(2, 0), false},
+		{"int", varintField(3, 42), float64(42)},
+		{"negative int", varintField(3, uint64(math.MaxUint64)), float64(-1)},
+		{"double", fixed64Field(4, math.Float64bits(1.5)), 1.5},
+		{"array", bytesField(5, bytesField(1, stringField(1, "a")), bytesField(1, varintField(3, 2))), []interface{}{"a", float64(2)}},
+		{"empty array", bytesField(5), []interface{}{}},
+		{"kvlist", bytesField(6, keyValue(1, "k", stringField(1, "v"))), map[string]interface{}{"k": "v"}},
+		{"bytes", bytesField(7, []byte{0, 1, 2}), "AAEC"},
+		{"empty", nil, nil},
+		{"unknown field", message(varintField(99, 1), stringField(1, "x")), "x"},
+		{"wrong type", fixed32Field(1, 7), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v AnyValue
+			if err := v.decode(tt.data); err != nil {
+				t.Fatalf("decode() error = %v", err)
+			}
+			if !reflect.DeepEqual(v.Value, tt.want) {
+				t.Errorf("decode() = %#v, want %#v", v.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeProtobuf(t *testing.T) {
+	record := bytesField(2,
+		fixed64Field(1, 1700000000000000000),
+		varintField(2, 17),
+		stringField(3, "ERROR"),
+		bytesField(5, stringField(1, "failed")),
+		keyValue(6, "user", stringField(1, "bob")),
+		fixed32Field(8, 1),
+		bytesField(9, []byte{0x5b, 0x8e}),
+		bytesField(10, []byte{0xef, 0x01}),
+		fixed64Field(11, 1700000000000000001),
+		stringField(12, "login"),
+	)
+	scope := bytesField(1, stringField(1, "lib"), stringField(2, "1.0"), keyValue(3, "a", varintField(2, 1)))
+	resource := bytesField(1, keyValue(1, "service.name", stringField(1, "api")))
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    *ExportLogsRequest
+		wantErr bool
+	}{
+		{
+			name: "logs",
+			data: bytesField(1, resource, bytesField(2, scope, record)),
+			want: &ExportLogsRequest{ResourceLogs: []ResourceLogs{{
+				Resource: Resource{Attributes: []KeyValue{{Key: "service.name", Value: AnyValue{"api"}}}},
+				ScopeLogs: []ScopeLogs{{
+					Scope: Scope{Name: "lib", Version: "1.0", Attributes: []KeyValue{{Key: "a", Value: AnyValue{true}}}},
+					LogRecords: []LogRecord{{
+						TimeUnixNano:         1700000000000000000,
+						ObservedTimeUnixNano: 1700000000000000001,
+						SeverityNumber:       17,
+						SeverityText:         "ERROR",
+						Body:                 AnyValue{"failed"},
+						Attributes:           []KeyValue{{Key: "user", Value: AnyValue{"bob"}}},
+						Flags:                1,
+						TraceID:              "5b8e",
+						SpanID:               "ef01",
+						EventName:            "login",
+					}},
+				}},
+			}}},
+		},
+		{
+			name: "instrumentation library logs",
+			data: bytesField(1, bytesField(1000, bytesField(2, stringField(3, "INFO")))),
+			want: &ExportLogsRequest{ResourceLogs: []ResourceLogs{{
+				InstrumentationLibraryLogs: []ScopeLogs{{LogRecords: []LogRecord{{SeverityText: "INFO"}}}},
+			}}},
+		},
+		{
+			name: "unknown fields",
+			data: message(varintField(7, 1), bytesField(1, stringField(9, "x"), bytesField(2, varintField(3, 1)))),
+			want: &ExportLogsRequest{ResourceLogs: []ResourceLogs{{ScopeLogs: []ScopeLogs{{}}}}},
+		},
+		{
+			name: "empty",
+			data: nil,
+			want: &ExportLogsRequest{},
+		},
+		{
+			name:    "truncated",
+			data:    bytesField(1, resource)[:5],
+			wantErr: true,
+		},
+		{
+			name:    "invalid nested message",
+			data:    bytesField(1, bytesField(2, []byte{0xff})),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeProtobuf(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeProtobuf() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeProtobuf() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeResponse(t *testing.T) {
+	partial := bytesField(1, varintField(1, 2), stringField(2, "bad"))
+
+	tests := []struct {
+		contentType string
+		rejected    int64
+		want        string
+	}{
+		{ContentTypeJSON, 0, "{}"},
+		{ContentTypeJSON, 2, `{"partialSuccess":{"errorMessage":"bad","rejectedLogRecords":"2"}}`},
+		{ContentTypeProtobuf, 0, ""},
+		{ContentTypeProtobuf, 2, string(partial)},
+	}
+
+	for _, tt := range tests {
+		if got := string(EncodeResponse(tt.contentType, tt.rejected, "bad")); got != tt.want {
+			t.Errorf("EncodeResponse(%s, %d) = %q, want %q", tt.contentType, tt.rejected, got, tt.want)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/prabhatsharma/zinc/pkg/auth"
 	"github.com/prabhatsharma/zinc/pkg/handlers"
 	v1 "github.com/prabhatsharma/zinc/pkg/meta/v1"
+	"github.com/prabhatsharma/zinc/pkg/middleware"
 )
 
 // SetRoutes sets up all gi HTTP API endpoints that can be called by front end
@@ -17,15 +18,16 @@ func SetRoutes(r *gin.Engine) {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "DELETE", "PUT", "HEAD", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "authorization", "content-type"},
+		AllowHeaders:     []string{"Origin", "authorization", "content-type", "content-encoding"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
+	// compressed request and response bodies
+	r.Use(middleware.Decompress, middleware.Compress)
 
 	// meta service - health
 	r.GET("/health", func(c *gin.Context) { c.JSON(200, gin.H{"status": "ok"}) })
-	r.GET("/", func(c *gin.Context) { c.Redirect(301, "/ui/") })
 	r.GET("/version", v1.GetVersion)
 	r.StaticFS("/ui", http.FS(zinc.FrontendAssets))
 
@@ -37,15 +39,84 @@ func SetRoutes(r *gin.Engine) {
 	r.PUT("/api/index", auth.ZincAuth, handlers.CreateIndex)
 	r.GET("/api/index", auth.ZincAuth, handlers.ListIndexes)
 	r.DELETE("/api/index/:indexName", auth.ZincAuth, handlers.DeleteIndex)
+	r.GET("/api/:target/_mapping", auth.ZincAuth, handlers.GetMapping)
+	r.PUT("/api/:target/_mapping", auth.ZincAuth, handlers.UpdateMapping)
+	r.GET("/api/:target/_settings", auth.ZincAuth, handlers.GetSettings)
+	r.PUT("/api/:target/_settings", auth.ZincAuth, handlers.UpdateSettings)
+
+	// Ingest pipelines
+	r.GET("/api/_pipeline", auth.ZincAuth, handlers.ListPipelines)
+	r.GET("/api/_pipeline/:name", auth.ZincAuth, handlers.GetPipeline)
+	r.PUT("/api/_pipeline/:name", auth.ZincAuth, handlers.UpdatePipeline)
+	r.DELETE("/api/_pipeline/:name", auth.ZincAuth, handlers.DeletePipeline)
 
 	// Bulk update/insert
 	r.POST("/api/_bulk", auth.ZincAuth, handlers.BulkHandler)
 	r.POST("/api/:target/_bulk", auth.ZincAuth, handlers.BulkHandler)
+	r.POST("/api/:target/_import", auth.ZincAuth, handlers.ImportHandler)
 
 	// Document CRUD APIs. Update is same as create.
 	r.PUT("/api/:target/doc", auth.ZincAuth, handlers.UpdateDoc)
 	r.POST("/api/:target/_doc", auth.ZincAuth, handlers.UpdateDoc)
 	r.PUT("/api/:target/_doc/:id", auth.ZincAuth, handlers.UpdateDoc)
 	r.POST("/api/:target/_search", auth.ZincAuth, handlers.SearchIndex)
+	r.GET("/api/:target/_explain/:id", auth.ZincAuth, handlers.ExplainDoc)
+	r.POST("/api/:target/_pit", auth.ZincAuth, handlers.OpenPointInTime)
+	r.DELETE("/api/_pit", auth.ZincAuth, handlers.ClosePointInTime)
 	r.DELETE("/api/:target/_doc/:id", auth.ZincAuth, handlers.DeleteDoc)
+	r.GET("/api/:target/_doc/:id", auth.ZincAuth, handlers.GetDoc)
+	r.POST("/api/:target/_update/:id", auth.ZincAuth, handlers.UpdateDocPartial)
+	r.POST("/api/_mget", auth.ZincAuth, handlers.MultiGetDocs)
+	r.POST("/api/:target/_mget", auth.ZincAuth, handlers.MultiGetDocs)
+
+	// Elasticsearch compatible API, for log shippers and other Elasticsearch clients.
+	// The root endpoint redirects browsers to the UI.
+	es := r.Group("/", handlers.ESHeaders)
+	es.GET("/", handlers.ESInfo)
+	es.HEAD("/", handlers.ESInfo)
+	es.GET("/_cluster/health", handlers.ESClusterHealth)
+	es.GET("/_xpack", handlers.ESXPack)
+	es.GET("/_license", handlers.ESXPack)
+	es.GET("/_cat/indices", auth.ZincAuth, handlers.ESCatIndices)
+
+	es.POST("/_bulk", auth.ZincAuth, handlers.BulkHandler)
+	es.PUT("/_bulk", auth.ZincAuth, handlers.BulkHandler)
+	es.POST("/:target/_bulk", auth.ZincAuth, handlers.BulkHandler)
+	es.PUT("/:target/_bulk", auth.ZincAuth, handlers.BulkHandler)
+
+	es.POST("/:target/_doc", auth.ZincAuth, handlers.ESWriteDoc)
+	es.PUT("/:target/_doc/:id", auth.ZincAuth, handlers.ESWriteDoc)
+	es.POST("/:target/_doc/:id", auth.ZincAuth, handlers.ESWriteDoc)
+	es.PUT("/:target/_create/:id", auth.ZincAuth, handlers.ESWriteDoc)
+	es.POST("/:target/_create/:id", auth.ZincAuth, handlers.ESWriteDoc)
+	es.POST("/:target/_update/:id", auth.ZincAuth, handlers.ESWriteDoc)
+	es.DELETE("/:target/_doc/:id", auth.ZincAuth, handlers.ESWriteDoc)
+	es.GET("/:target/_doc/:id", auth.ZincAuth, handlers.GetDoc)
+	es.POST("/_mget", auth.ZincAuth, handlers.MultiGetDocs)
+	es.POST("/:target/_mget", auth.ZincAuth, handlers.MultiGetDocs)
+
+	es.GET("/:target/_search", auth.ZincAuth, handlers.ESSearch)
+	es.POST("/:target/_search", auth.ZincAuth, handlers.ESSearch)
+
+	// Mappings and templates are accepted but not applied, Zinc infers the field types
+	es.GET("/:target/_mapping", auth.ZincAuth, handlers.ESGetMapping)
+	es.PUT("/:target/_mapping", auth.ZincAuth, handlers.ESAcknowledge)
+	es.GET("/_template/:name", auth.ZincAuth, handlers.ESGetTemplate)
+	es.HEAD("/_template/:name", auth.ZincAuth, handlers.ESGetTemplate)
+	es.PUT("/_template/:name", auth.ZincAuth, handlers.ESAcknowledge)
+	es.GET("/_index_template/:name", auth.ZincAuth, handlers.ESGetTemplate)
+	es.HEAD("/_index_template/:name", auth.ZincAuth, handlers.ESGetTemplate)
+	es.PUT("/_index_template/:name", auth.ZincAuth, handlers.ESAcknowledge)
+
+	// OpenTelemetry OTLP/HTTP logs receiver
+	r.POST("/v1/logs", auth.ZincAuth, handlers.OTLPLogs)
+
+	// Splunk HTTP Event Collector API, authenticated with HEC tokens
+	r.POST("/services/collector", auth.HECAuth, handlers.HECEvent)
+	r.POST("/services/collector/event", auth.HECAuth, handlers.HECEvent)
+	r.POST("/services/collector/event/1.0", auth.HECAuth, handlers.HECEvent)
+	r.POST("/services/collector/raw", auth.HECAuth, handlers.HECRaw)
+	r.POST("/services/collector/raw/1.0", auth.HECAuth, handlers.HECRaw)
+	r.GET("/services/collector/health", handlers.HECHealth)
+	r.GET("/services/collector/health/1.0", handlers.HECHealth)
 }
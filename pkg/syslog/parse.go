@@ -0,0 +1,291 @@
+package syslog
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var facilityNames = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var severityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// defaultPriority is the priority of a message without one, user.notice as in RFC 3164.
+const defaultPriority = 13
+
+// Message is a syslog message. Fields missing from the message are empty.
+type Message struct {
+	Priority  int
+	Timestamp time.Time // the time the message was received if it has none
+	Hostname  string
+	AppName   string
+	ProcID    string
+	MsgID     string
+	// StructuredData maps the id of each SD-ELEMENT to its parameters, RFC 5424 only.
+	StructuredData map[string]map[string]string
+	Message        string
+}
+
+// Facility returns the name of the facility of the message, e.g. daemon.
+func (m *Message) Facility() string {
+	if f := m.Priority / 8; f < len(facilityNames) {
+		return facilityNames[f]
+	}
+	return strconv.Itoa(m.Priority / 8)
+}
+
+// Severity returns the name of the severity of the message, e.g. err.
+func (m *Message) Severity() string {
+	return severityNames[m.Priority%8]
+}
+
+// Doc returns the document of the message to index.
+func (m *Message) Doc() map[string]interface{} {
+	doc := map[string]interface{}{
+		"@timestamp": m.Timestamp.Format(time.RFC3339Nano),
+		"priority":   float64(m.Priority),
+		"facility":   m.Facility(),
+		"severity":   m.Severity(),
+		"message":    m.Message,
+	}
+	for field, value := range map[string]string{"hostname": m.Hostname, "app_name": m.AppName, "proc_id": m.ProcID, "msg_id": m.MsgID} {
+		if value != "" {
+			doc[field] = value
+		}
+	}
+	if len(m.StructuredData) > 0 {
+		sd := make(map[string]interface{}, len(m.StructuredData))
+		for id, params := range m.StructuredData {
+			p := make(map[string]interface{}, len(params))
+			for name, value := range params {
+				p[name] = value
+			}
+			sd[id] = p
+		}
+		doc["structured_data"] = sd
+	}
+
+	return doc
+}
+
+// Parse parses a syslog message in the format of RFC 5424, or else of RFC 3164, the BSD syslog format.
+// Parsing never fails: what cannot be parsed is kept in the message text.
+// Timestamps without a year or a time zone are taken in the year and the time zone of received.
+func Parse(data []byte, received time.Time) *Message {
+	line := string(bytes.TrimRight(data, "\r\n\x00"))
+	m := &Message{Priority: defaultPriority, Timestamp: received}
+
+	rest, ok := parsePriority(line, m)
+	if !ok {
+		m.Message = line
+		return m
+	}
+
+	if strings.HasPrefix(rest, "1 ") && parse5424(rest[2:], m) {
+		return m
+	}
+	parse3164(rest, m, received)
+	return m
+}
+
+// parsePriority parses the <PRI> prefix of the line and returns the rest of the line.
+func parsePriority(line string, m *Message) (string, bool) {
+	end := strings.IndexByte(line, '>')
+	if !strings.HasPrefix(line, "<") || end < 2 || end > 4 {
+		return line, false
+	}
+
+	pri, err := strconv.Atoi(line[1:end])
+	if err != nil || pri < 0 || pri > 191 {
+		return line, false
+	}
+
+	m.Priority = pri
+	return line[end+1:], true
+}
+
+// parse5424 parses the header, the structured data and the message of RFC 5424, after the version.
+// It returns false, leaving the message unchanged, if the header is malformed.
+func parse5424(rest string, m *Message) bool {
+	var header [5]string
+	for i := range header {
+		var ok bool
+		if header[i], rest, ok = nextToken(rest); !ok {
+			return false
+		}
+	}
+
+	timestamp := m.Timestamp
+	if header[0] != "-" {
+		t, err := time.Parse(time.RFC3339Nano, header[0])
+		if err != nil {
+			return false
+		}
+		timestamp = t
+	}
+
+	var sd map[string]map[string]string
+	switch {
+	case strings.HasPrefix(rest, "-"):
+		rest = rest[1:]
+	case strings.HasPrefix(rest, "["):
+		var n int
+		var ok bool
+		if sd, n, ok = parseStructuredData(rest); !ok {
+			return false
+		}
+		rest = rest[n:]
+	default:
+		return false
+	}
+
+	// The message is only set once the header is known to be well formed, so that a malformed one is parsed as RFC 3164
+	m.Timestamp, m.StructuredData = timestamp, sd
+	m.Hostname, m.AppName, m.ProcID, m.MsgID = nilValue(header[1]), nilValue(header[2]), nilValue(header[3]), nilValue(header[4])
+
+	rest = strings.TrimPrefix(rest, " ")
+	m.Message = strings.TrimPrefix(rest, "\ufeff") // UTF-8 byte order mark of the message
+	return true
+}
+
+// parseStructuredData parses the SD-ELEMENTs at the start of s, e.g. [id@32473 key="value"], and returns their length.
+func parseStructuredData(s string) (map[string]map[string]string, int, bool) {
+	sd := make(map[string]map[string]string)
+	i := 0
+	for i < len(s) && s[i] == '[' {
+		i++
+		start := i
+		for i < len(s) && s[i] != ' ' && s[i] != ']' {
+			i++
+		}
+		if i == len(s) || i == start {
+			return nil, 0, false
+		}
+		params := make(map[string]string)
+		sd[s[start:i]] = params
+
+		for i < len(s) && s[i] == ' ' {
+			i++
+			eq := strings.IndexByte(s[i:], '=')
+			if eq < 1 || i+eq+1 >= len(s) || s[i+eq+1] != '"' {
+				return nil, 0, false
+			}
+			name := s[i : i+eq]
+			i += eq + 2
+
+			var value strings.Builder
+			for i < len(s) && s[i] != '"' {
+				if s[i] == '\\' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\' || s[i+1] == ']') {
+					i++
+				}
+				value.WriteByte(s[i])
+				i++
+			}
+			if i == len(s) {
+				return nil, 0, false
+			}
+			params[name] = value.String()
+			i++ // closing quote
+		}
+
+		if i == len(s) || s[i] != ']' {
+			return nil, 0, false
+		}
+		i++
+	}
+
+	return sd, i, true
+}
+
+// rfc3164Layouts are the timestamp layouts of BSD syslog messages, longest first.
+var rfc3164Layouts = []string{
+	"Jan _2 15:04:05.000000", // with microseconds, as sent by some network devices
+	"Jan _2 2006 15:04:05",   // with the year
+	time.Stamp,
+	"Jan 2 15:04:05", // day without padding
+}
+
+// parse3164 parses the timestamp, the hostname and the tag of a BSD syslog message. All are optional,
+// a message that does not follow the format is kept whole.
+func parse3164(rest string, m *Message, received time.Time) {
+	rest = strings.TrimLeft(rest, " ")
+
+	// Timestamp: RFC 3339 as sent by rsyslog, or the BSD format without year and time zone
+	hasTimestamp := false
+	if token, after, ok := nextToken(rest); ok {
+		if t, err := time.Parse(time.RFC3339Nano, token); err == nil {
+			m.Timestamp, rest, hasTimestamp = t, after, true
+		} else {
+			for _, layout := range rfc3164Layouts {
+				n := len(layout)
+				if len(rest) < n || len(rest) > n && rest[n] != ' ' {
+					continue
+				}
+				t, err := time.ParseInLocation(layout, rest[:n], received.Location())
+				if err != nil {
+					continue
+				}
+				if t.Year() == 0 {
+					t = withYear(t, received)
+				}
+				m.Timestamp, rest, hasTimestamp = t, strings.TrimPrefix(rest[n:], " "), true
+				break
+			}
+		}
+	}
+
+	// Hostname after the timestamp, unless the next token is already the tag, e.g. sshd[42]: or su:
+	if token, after, ok := nextToken(rest); ok && hasTimestamp && !isTag(token) {
+		m.Hostname, rest = token, after
+	}
+
+	// Tag: the app name with an optional process id
+	if token, after, ok := nextToken(rest); ok && isTag(token) {
+		tag := strings.TrimSuffix(token, ":")
+		if open := strings.IndexByte(tag, '['); open > 0 && strings.HasSuffix(tag, "]") {
+			m.AppName, m.ProcID = tag[:open], tag[open+1:len(tag)-1]
+		} else {
+			m.AppName = tag
+		}
+		rest = after
+	}
+
+	m.Message = rest
+}
+
+// withYear sets the year of a timestamp without one: the year the message was received,
+// or the year before for a timestamp more than a day in the future, sent at the end of December.
+func withYear(t, received time.Time) time.Time {
+	t = t.AddDate(received.Year(), 0, 0)
+	if t.After(received.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t
+}
+
+// isTag reports whether the token is the tag of a BSD syslog message, e.g. sshd[42]:, su: or CRON[7].
+func isTag(token string) bool {
+	return strings.HasSuffix(token, ":") || strings.HasSuffix(token, "]")
+}
+
+// nextToken returns the text before the next space and the text after it.
+func nextToken(s string) (string, string, bool) {
+	i := strings.IndexByte(s, ' ')
+	if i <= 0 {
+		return "", s, false
+	}
+	return s[:i], s[i+1:], true
+}
+
+// nilValue returns the empty string for the NILVALUE of RFC 5424.
+func nilValue(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
@@ -0,0 +1,160 @@
+package syslog
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	received := time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		data string
+		want Message
+	}{
+		{
+			name: "RFC 5424",
+			data: "<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog 42 ID47 - An application event\n",
+			want: Message{
+				Priority:  165,
+				Timestamp: time.Date(2003, time.October, 11, 22, 14, 15, 3000000, time.UTC),
+				Hostname:  "mymachine.example.com",
+				AppName:   "evntslog",
+				ProcID:    "42",
+				MsgID:     "ID47",
+				Message:   "An application event",
+			},
+		},
+		{
+			name: "RFC 5424 with structured data and a byte order mark",
+			data: `<165>1 2003-10-11T22:14:15.003Z host app - ID47 [exampleSDID@32473 iut="3" eventSource="Appl\"ication"][meta seq="1"] ` + "\ufeffmsg",
+			want: Message{
+				Priority:  165,
+				Timestamp: time.Date(2003, time.October, 11, 22, 14, 15, 3000000, time.UTC),
+				Hostname:  "host",
+				AppName:   "app",
+				MsgID:     "ID47",
+				StructuredData: map[string]map[string]string{
+					"exampleSDID@32473": {"iut": "3", "eventSource": `Appl"ication`},
+					"meta":              {"seq": "1"},
+				},
+				Message: "msg",
+			},
+		},
+		{
+			name: "RFC 5424 with nil values",
+			data: "<14>1 - - - - - -",
+			want: Message{Priority: 14, Timestamp: received},
+		},
+		{
+			name: "RFC 5424 with malformed structured data",
+			data: "<14>1 - host app - - [id x=1] msg",
+			want: Message{Priority: 14, Timestamp: received, Message: "1 - host app - - [id x=1] msg"},
+		},
+		{
+			name: "RFC 3164",
+			data: "<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8",
+			want: Message{
+				Priority:  34,
+				Timestamp: time.Date(2023, time.October, 11, 22, 14, 15, 0, time.UTC),
+				Hostname:  "mymachine",
+				AppName:   "su",
+				Message:   "'su root' failed for lonvick on /dev/pts/8",
+			},
+		},
+		{
+			name: "RFC 3164 with a process id and a padded day",
+			data: "<38>Mar  9 08:00:00 web1 sshd[4242]: Accepted publickey",
+			want: Message{
+				Priority:  38,
+				Timestamp: time.Date(2024, time.March, 9, 8, 0, 0, 0, time.UTC),
+				Hostname:  "web1",
+				AppName:   "sshd",
+				ProcID:    "4242",
+				Message:   "Accepted publickey",
+			},
+		},
+		{
+			name: "RFC 3164 with microseconds",
+			data: "<190>Mar 10 11:59:59.123456 router %LINK-3-UPDOWN: down",
+			want: Message{
+				Priority:  190,
+				Timestamp: time.Date(2024, time.March, 10, 11, 59, 59, 123456000, time.UTC),
+				Hostname:  "router",
+				AppName:   "%LINK-3-UPDOWN",
+				Message:   "down",
+			},
+		},
+		{
+			name: "RFC 3164 with the year",
+			data: "<13>Jan  2 2020 03:04:05 host msg",
+			want: Message{
+				Priority:  13,
+				Timestamp: time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC),
+				Hostname:  "host",
+				Message:   "msg",
+			},
+		},
+		{
+			name: "RFC 3164 with an RFC 3339 timestamp",
+			data: "<13>2024-03-10T10:00:00+02:00 host app: msg",
+			want: Message{
+				Priority:  13,
+				Timestamp: time.Date(2024, time.March, 10, 8, 0, 0, 0, time.UTC),
+				Hostname:  "host",
+				AppName:   "app",
+				Message:   "msg",
+			},
+		},
+		{
+			name: "RFC 3164 without a timestamp",
+			data: "<13>CRON[7]: job done",
+			want: Message{Priority: 13, Timestamp: received, AppName: "CRON", ProcID: "7", Message: "job done"},
+		},
+		{
+			name: "without a priority",
+			data: "just some text\r\n",
+			want: Message{Priority: defaultPriority, Timestamp: received, Message: "just some text"},
+		},
+		{
+			name: "with a priority out of range",
+			data: "<192>text",
+			want: Message{Priority: defaultPriority, Timestamp: received, Message: "<192>text"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse([]byte(tt.data), received)
+			if !got.Timestamp.Equal(tt.want.Timestamp) {
+				t.Errorf("Timestamp = %v, want %v", got.Timestamp, tt.want.Timestamp)
+			}
+			got.Timestamp, tt.want.Timestamp = time.Time{}, time.Time{}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageFacilityAndSeverity(t *testing.T) {
+	tests := []struct {
+		priority int
+		facility string
+		severity string
+	}{
+		{0, "kern", "emerg"},
+		{13, "user", "notice"},
+		{34, "auth", "crit"},
+		{191, "local7", "debug"},
+	}
+
+	for _, tt := range tests {
+		m := &Message{Priority: tt.priority}
+		if m.Facility() != tt.facility || m.Severity() != tt.severity {
+			t.Errorf("priority %d: %s.%s, want %s.%s", tt.priority, m.Facility(), m.Severity(), tt.facility, tt.severity)
+		}
+	}
+}
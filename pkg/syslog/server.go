@@ -0,0 +1,246 @@
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prabhatsharma/zinc/pkg/core"
+	"github.com/prabhatsharma/zinc/pkg/zutil"
+)
+
+// maxMessageSize is the size of the longest message, longer messages are truncated.
+const maxMessageSize = 64 * 1024
+
+// Start starts the UDP and TCP syslog listeners set in the environment. The listeners run in the background.
+// No listener is started unless an address is set, e.g. ZINC_SYSLOG_UDP=:5514.
+func Start() error {
+	udpAddr := zutil.GetEnv("ZINC_SYSLOG_UDP", "")
+	tcpAddr := zutil.GetEnv("ZINC_SYSLOG_TCP", "")
+	if udpAddr == "" && tcpAddr == "" {
+		return nil
+	}
+
+	targetIndex := zutil.GetEnv("ZINC_SYSLOG_INDEX", "syslog")
+	b := &batcher{
+		index:         targetIndex,
+		pipeline:      zutil.GetEnv("ZINC_SYSLOG_PIPELINE", ""),
+		batchSize:     zutil.GetEnvInt("ZINC_SYSLOG_BATCH_SIZE", 1000),
+		flushInterval: time.Duration(zutil.GetEnvInt("ZINC_SYSLOG_FLUSH_MS", 1000)) * time.Millisecond,
+	}
+	b.docs = make(chan map[string]interface{}, b.batchSize)
+
+	if udpAddr != "" {
+		conn, err := net.ListenPacket("udp", udpAddr)
+		if err != nil {
+			return err
+		}
+		log.Printf("Syslog listening on udp %s, writing to index %s", conn.LocalAddr(), targetIndex)
+		go serveUDP(conn, b)
+	}
+	if tcpAddr != "" {
+		l, err := net.Listen("tcp", tcpAddr)
+		if err != nil {
+			return err
+		}
+		log.Printf("Syslog listening on tcp %s, writing to index %s", l.Addr(), targetIndex)
+		go serveTCP(l, b)
+	}
+
+	go b.run()
+	return nil
+}
+
+// serveUDP reads a message from every datagram, or a message from every line of a datagram that has several.
+func serveUDP(conn net.PacketConn, b *batcher) {
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("Error reading syslog datagram: %v", err)
+			continue
+		}
+
+		for _, line := range bytes.Split(buf[:n], []byte("\n")) {
+			if len(bytes.TrimSpace(line)) > 0 {
+				b.add(line, addr)
+			}
+		}
+	}
+}
+
+func serveTCP(l net.Listener, b *batcher) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("Error accepting syslog connection: %v", err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		go serveTCPConn(conn, b)
+	}
+}
+
+// serveTCPConn reads the messages of a connection until it is closed.
+func serveTCPConn(conn net.Conn, b *batcher) {
+	defer conn.Close()
+
+	r := bufio.NewReaderSize(conn, maxMessageSize)
+	for {
+		frame, err := readFrame(r)
+		if len(bytes.TrimSpace(frame)) > 0 {
+			b.add(frame, conn.RemoteAddr())
+		}
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("Error reading syslog connection from %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+	}
+}
+
+// readFrame reads the next message of a TCP stream. Messages are framed by octet counting, "LEN SP MSG"
+// as in RFC 6587, if they start with a length followed by a space and the "<" of the priority, or else end with a
+// newline. Messages longer than maxMessageSize are truncated.
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	n, ok, err := octetCount(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		size := n
+		if size > maxMessageSize {
+			size = maxMessageSize
+		}
+		frame := make([]byte, size)
+		if _, err := io.ReadFull(r, frame); err != nil {
+			return nil, err
+		}
+		_, err := r.Discard(n - size)
+		return frame, err
+	}
+
+	line, err := r.ReadSlice('\n')
+	frame := append([]byte(nil), line...)
+	for err == bufio.ErrBufferFull { // skip the rest of a long line
+		_, err = r.ReadSlice('\n')
+	}
+	return frame, err
+}
+
+// maxOctetCountDigits is the most digits of the length of an octet counted frame.
+const maxOctetCountDigits = 9
+
+// octetCount reads the "LEN SP" of an octet counted frame and returns the length, if the stream continues with one
+// followed by the "<" of the priority. Otherwise nothing is read and ok is false. It only waits for the bytes it
+// needs to tell, so a short newline framed message is not held back.
+func octetCount(r *bufio.Reader) (n int, ok bool, err error) {
+	for i := 0; i <= maxOctetCountDigits+1; i++ {
+		b, err := r.Peek(i + 1)
+		if err != nil {
+			if err == io.EOF && len(b) > 0 {
+				return 0, false, nil // a last message without a newline
+			}
+			return 0, false, err
+		}
+
+		c := b[i]
+		switch {
+		case c >= '0' && c <= '9' && i < maxOctetCountDigits && !(i == 0 && c == '0'):
+			n = n*10 + int(c-'0')
+			continue
+		case c == ' ' && i > 0:
+			if next, err := r.Peek(i + 2); err == nil && next[i+1] == '<' {
+				_, err = r.Discard(i + 1)
+				return n, true, err
+			}
+		}
+		return 0, false, nil
+	}
+	return 0, false, nil
+}
+
+// batcher writes the messages to the index in batches of batchSize, or of what was received in flushInterval.
+type batcher struct {
+	index         string
+	pipeline      string
+	batchSize     int
+	flushInterval time.Duration
+	docs          chan map[string]interface{}
+}
+
+// add queues the message for the next batch. It blocks while the batch is written if the queue is full.
+func (b *batcher) add(data []byte, from net.Addr) {
+	doc := Parse(data, time.Now()).Doc()
+	if host, _, err := net.SplitHostPort(from.String()); err == nil {
+		doc["source_ip"] = host
+	}
+	b.docs <- doc
+}
+
+func (b *batcher) run() {
+	ticker := time.NewTicker(b.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]map[string]interface{}, 0, b.batchSize)
+	for {
+		select {
+		case doc := <-b.docs:
+			if batch = append(batch, doc); len(batch) < b.batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+
+		if err := b.write(batch); err != nil {
+			log.Printf("Error writing %d syslog messages to index %s: %v", len(batch), b.index, err)
+		}
+		batch = batch[:0]
+	}
+}
+
+// write indexes the documents through the pipeline of the listeners, if any, with a single DocWriter. The documents
+// are run through the pipeline before the first write, which locks the index.
+func (b *batcher) write(docs []map[string]interface{}) error {
+	index, err := core.GetIndex(b.index)
+	if err != nil {
+		return err
+	}
+
+	prepared := make([]map[string]interface{}, 0, len(docs))
+	for _, doc := range docs {
+		doc, err := index.ProcessDoc(b.pipeline, doc)
+		if err != nil {
+			log.Printf("Error processing syslog message with pipeline %s: %v", b.pipeline, err)
+			continue
+		}
+		if doc != nil { // else dropped by the pipeline
+			prepared = append(prepared, doc)
+		}
+	}
+
+	w := index.NewDocWriter()
+	for _, doc := range prepared {
+		if _, err := w.Index(uuid.New().String(), doc, true, core.Precondition{}); err != nil {
+			log.Printf("Error indexing syslog message: %v", err)
+		}
+	}
+
+	return w.Flush()
+}
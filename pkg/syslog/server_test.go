@@ -0,0 +1,99 @@
+package syslog
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestReadFrame(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		frames []string
+	}{
+		{
+			name:   "octet counting",
+			stream: "11 <13>hello a5 <14>b",
+			frames: []string{"<13>hello a", "<14>b"},
+		},
+		{
+			name:   "octet counting with newlines in the message",
+			stream: "9 <13>a\nb c",
+			frames: []string{"<13>a\nb c"},
+		},
+		{
+			name:   "newline",
+			stream: "<13>hello\n<14>world\n",
+			frames: []string{"<13>hello\n", "<14>world\n"},
+		},
+		{
+			name:   "newline starting with a digit",
+			stream: "2024-01-01 12:00:00 host msg\n",
+			frames: []string{"2024-01-01 12:00:00 host msg\n"},
+		},
+		{
+			name:   "newline starting with digits and a space",
+			stream: "12 apples\n3\n",
+			frames: []string{"12 apples\n", "3\n"},
+		},
+		{
+			name:   "newline starting with a zero",
+			stream: "05 <13>x\n",
+			frames: []string{"05 <13>x\n"},
+		},
+		{
+			name:   "last message without a newline",
+			stream: "<13>a\n42",
+			frames: []string{"<13>a\n", "42"},
+		},
+		{
+			name:   "both framings",
+			stream: "6 <13>ab<14>cd\n7 <15>efg",
+			frames: []string{"<13>ab", "<14>cd\n", "<15>efg"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReaderSize(strings.NewReader(tt.stream), maxMessageSize)
+			var frames []string
+			for {
+				frame, err := readFrame(r)
+				if len(frame) > 0 {
+					frames = append(frames, string(frame))
+				}
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("readFrame() error = %v", err)
+				}
+			}
+			if !reflect.DeepEqual(frames, tt.frames) {
+				t.Errorf("frames = %q, want %q", frames, tt.frames)
+			}
+		})
+	}
+}
+
+func TestReadFrameTruncated(t *testing.T) {
+	msg := "<13>" + strings.Repeat("x", maxMessageSize)
+	stream := strconv.Itoa(len(msg)) + " " + msg + "5 <14>b"
+	r := bufio.NewReaderSize(strings.NewReader(stream), maxMessageSize)
+
+	frame, err := readFrame(r)
+	if err != nil {
+		t.Fatalf("readFrame() error = %v", err)
+	}
+	if len(frame) != maxMessageSize {
+		t.Errorf("len(frame) = %d, want %d", len(frame), maxMessageSize)
+	}
+	frame, err = readFrame(r)
+	if err != nil || string(frame) != "<14>b" {
+		t.Errorf("next frame = %q, %v, want %q", frame, err, "<14>b")
+	}
+}
@@ -0,0 +1,860 @@
+package uquery
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/numeric"
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+	v1 "github.com/prabhatsharma/zinc/pkg/meta/v1"
+)
+
+// aggPrefix prefixes the names of the aggregations of a query in the search request, to keep them apart from
+// the standard aggregations, count, max_score and duration, and from the count of each bucket.
+const aggPrefix = "agg:"
+
+// maxBuckets is the most buckets a histogram fills its gaps up to.
+const maxBuckets = 10000
+
+var defaultPercents = []float64{1, 5, 25, 50, 75, 95, 99}
+
+// Field types that cannot be aggregated or that aggregate differently, see core.FieldTypeText and others.
+const (
+	fieldTypeText   = "text"
+	fieldTypeStored = "stored"
+)
+
+// AddAggregations adds the aggregations of the query to the search request.
+//
+// Supported aggregations are terms, range, histogram and date_histogram, which can be nested,
+// and the metrics min, max, avg, sum, cardinality and percentiles.
+func AddAggregations(req bluge.SearchRequest, aggs map[string]v1.AggregationRequest, fieldTypes map[string]string) error {
+	loaded := make(map[string]bool)
+	if topN, ok := req.(*bluge.TopNSearch); ok {
+		for _, field := range topN.SortOrder().Fields() {
+			loaded[field] = true
+		}
+	}
+
+	for name, agg := range aggs {
+		a, err := compileAggregation(name, agg, aggFields(fieldTypes))
+		if err != nil {
+			return err
+		}
+		req.AddAggregation(aggPrefix+name, loadOnce(a, loaded))
+	}
+	return nil
+}
+
+// loadedOnce is an aggregation that asks for the fields that the sort and the other aggregations of the search
+// do not ask for already. Bluge loads the doc values of a field once for every time it is asked for, so the
+// values of a field would repeat.
+type loadedOnce struct {
+	search.Aggregation
+	fields []string
+}
+
+// loadOnce returns the aggregation asking for the fields that are not loaded yet, and adds them to loaded.
+func loadOnce(a search.Aggregation, loaded map[string]bool) search.Aggregation {
+	var fields []string
+	for _, field := range a.Fields() {
+		if !loaded[field] {
+			loaded[field] = true
+			fields = append(fields, field)
+		}
+	}
+	return loadedOnce{Aggregation: a, fields: fields}
+}
+
+func (a loadedOnce) Fields() []string {
+	return a.fields
+}
+
+// AggregationResults returns the results of the aggregations of the query from the aggregations of the search.
+func AggregationResults(aggs map[string]v1.AggregationRequest, fieldTypes map[string]string, results *search.Bucket) map[string]v1.AggregationResponse {
+	if len(aggs) == 0 {
+		return nil
+	}
+
+	resp := make(map[string]v1.AggregationResponse, len(aggs))
+	for name, agg := range aggs {
+		resp[name] = aggregationResult(agg, aggFields(fieldTypes), results.Aggregation(aggPrefix+name))
+	}
+	return resp
+}
+
+// aggFields are the field types of the mapping of the index.
+type aggFields map[string]string
+
+func (t aggFields) typ(field string) string {
+	if field == "@timestamp" {
+		return fieldTypeDate
+	}
+	if typ := t[field]; typ != fieldTypeTime {
+		return typ
+	}
+	return fieldTypeDate
+}
+
+// aggregationKind returns the type of the aggregation, which must have exactly one.
+func aggregationKind(name string, agg v1.AggregationRequest) (string, error) {
+	var kinds []string
+	for kind, set := range map[string]bool{
+		"terms":          agg.Terms != nil,
+		"range":          agg.Range != nil,
+		"histogram":      agg.Histogram != nil,
+		"date_histogram": agg.DateHistogram != nil,
+		"min":            agg.Min != nil,
+		"max":            agg.Max != nil,
+		"avg":            agg.Avg != nil,
+		"sum":            agg.Sum != nil,
+		"cardinality":    agg.Cardinality != nil,
+		"percentiles":    agg.Percentiles != nil,
+	} {
+		if set {
+			kinds = append(kinds, kind)
+		}
+	}
+
+	if len(kinds) != 1 {
+		sort.Strings(kinds)
+		return "", fmt.Errorf("aggregation [%s] must have exactly one type, found %v", name, kinds)
+	}
+	return kinds[0], nil
+}
+
+func compileAggregation(name string, agg v1.AggregationRequest, types aggFields) (search.Aggregation, error) {
+	kind, err := aggregationKind(name, agg)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := make(map[string]search.Aggregation, len(agg.Aggs)+1)
+	for subName, subAgg := range agg.Aggs {
+		a, err := compileAggregation(subName, subAgg, types)
+		if err != nil {
+			return nil, err
+		}
+		sub[aggPrefix+subName] = a
+	}
+
+	var a search.Aggregation
+	switch kind {
+	case "terms":
+		a, err = termsAggregation(agg.Terms, types, sub)
+	case "range":
+		a, err = rangeAggregation(agg.Range, types, sub)
+	case "histogram":
+		a, err = histogramAggregation(agg.Histogram, types, sub)
+	case "date_histogram":
+		a, err = dateHistogramAggregation(agg.DateHistogram, types, sub)
+	default:
+		if len(agg.Aggs) > 0 {
+			return nil, fmt.Errorf("aggregation [%s]: %s cannot have nested aggregations", name, kind)
+		}
+		a, err = metricAggregation(kind, agg, types)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("aggregation [%s]: %v", name, err)
+	}
+	return a, nil
+}
+
+func termsAggregation(terms *v1.TermsAggregation, types aggFields, sub map[string]search.Aggregation) (search.Aggregation, error) {
+	if terms.Field == "" {
+		return nil, fmt.Errorf("[terms] field is required")
+	}
+	keys, err := termKeys(terms.Field, types.typ(terms.Field))
+	if err != nil {
+		return nil, err
+	}
+
+	size := terms.Size
+	if size == 0 {
+		size = 10
+	}
+	minDocCount := terms.MinDocCount
+	if minDocCount == 0 {
+		minDocCount = 1
+	}
+	if size < 0 || minDocCount < 0 {
+		return nil, fmt.Errorf("[terms] size and min_doc_count cannot be negative")
+	}
+
+	// By count, descending, then by key, as Elasticsearch
+	less := func(a, b *keyedBucket) bool {
+		if a.Count() != b.Count() {
+			return a.Count() > b.Count()
+		}
+		return a.less(b)
+	}
+	for by, dir := range terms.Order {
+		if dir != "asc" && dir != "desc" {
+			return nil, fmt.Errorf("[terms] invalid order [%s], expected asc or desc", dir)
+		}
+		desc := dir == "desc"
+		switch by {
+		case "_count":
+			less = func(a, b *keyedBucket) bool {
+				if a.Count() != b.Count() {
+					return (a.Count() > b.Count()) == desc
+				}
+				return a.less(b)
+			}
+		case "_key":
+			less = func(a, b *keyedBucket) bool { return a.less(b) != desc }
+		default:
+			return nil, fmt.Errorf("[terms] invalid order [%s], expected _count or _key", by)
+		}
+	}
+
+	return newBucketAggregation(terms.Field, keys, sub, func(c *bucketCalculator) {
+		c.keep(func(b *keyedBucket) bool { return b.Count() >= uint64(minDocCount) })
+		sort.SliceStable(c.list, func(i, j int) bool { return less(c.list[i], c.list[j]) })
+		if len(c.list) > size {
+			for _, b := range c.list[size:] {
+				c.other += int(b.Count())
+			}
+			c.list = c.list[:size]
+		}
+	}), nil
+}
+
+func rangeAggregation(r *v1.RangeAggregation, types aggFields, sub map[string]search.Aggregation) (search.Aggregation, error) {
+	if r.Field == "" {
+		return nil, fmt.Errorf("[range] field is required")
+	}
+	if len(r.Ranges) == 0 {
+		return nil, fmt.Errorf("[range] ranges are required")
+	}
+
+	switch types.typ(r.Field) {
+	case fieldTypeNumeric, "": // fields without values yet have no type
+		a := aggregations.Ranges(search.Field(r.Field))
+		for _, rang := range r.Ranges {
+			from, to, err := numericRange(rang)
+			if err != nil {
+				return nil, err
+			}
+			a.AddRange(aggregations.NamedRange(rangeKey(rang, from, to), from, to))
+		}
+		for name, agg := range sub {
+			a.AddAggregation(name, agg)
+		}
+		return a, nil
+	case fieldTypeDate:
+		a := aggregations.DateRanges(search.Field(r.Field))
+		for _, rang := range r.Ranges {
+			from, to, err := dateRange(rang)
+			if err != nil {
+				return nil, err
+			}
+			a.AddRange(aggregations.NewNamedDateRange(rangeKey(rang, from, to), from, to))
+		}
+		for name, agg := range sub {
+			a.AddAggregation(name, agg)
+		}
+		return a, nil
+	}
+
+	return nil, fmt.Errorf("[range] field [%s] is not a numeric or date field", r.Field)
+}
+
+// numericRange returns the bounds of the range, infinite if not given.
+func numericRange(r v1.AggregationRange) (float64, float64, error) {
+	from, to := math.Inf(-1), math.Inf(1)
+	var err error
+	if r.From != nil {
+		if from, err = dslNumber(r.From); err != nil {
+			return 0, 0, fmt.Errorf("[range] from: %v", err)
+		}
+	}
+	if r.To != nil {
+		if to, err = dslNumber(r.To); err != nil {
+			return 0, 0, fmt.Errorf("[range] to: %v", err)
+		}
+	}
+	return from, to, nil
+}
+
+// dateRange returns the bounds of the range, zero if not given.
+func dateRange(r v1.AggregationRange) (time.Time, time.Time, error) {
+	var from, to time.Time
+	var err error
+	if r.From != nil {
+		if from, err = dslDate(r.From, "", time.UTC); err != nil {
+			return from, to, fmt.Errorf("[range] from: %v", err)
+		}
+	}
+	if r.To != nil {
+		if to, err = dslDate(r.To, "", time.UTC); err != nil {
+			return from, to, fmt.Errorf("[range] to: %v", err)
+		}
+	}
+	return from, to, nil
+}
+
+// rangeKey returns the key of the range, or else "from-to" with * for a missing bound, e.g. *-100.0.
+func rangeKey(r v1.AggregationRange, from, to interface{}) string {
+	if r.Key != "" {
+		return r.Key
+	}
+
+	bound := func(v interface{}, set bool) string {
+		if !set {
+			return "*"
+		}
+		switch v := v.(type) {
+		case float64:
+			return formatFloatKey(v)
+		case time.Time:
+			return v.UTC().Format(time.RFC3339Nano)
+		}
+		return fmt.Sprint(v)
+	}
+	return bound(from, r.From != nil) + "-" + bound(to, r.To != nil)
+}
+
+func histogramAggregation(h *v1.HistogramAggregation, types aggFields, sub map[string]search.Aggregation) (search.Aggregation, error) {
+	if h.Field == "" {
+		return nil, fmt.Errorf("[histogram] field is required")
+	}
+	if typ := types.typ(h.Field); typ != fieldTypeNumeric && typ != "" {
+		return nil, fmt.Errorf("[histogram] field [%s] is not a numeric field", h.Field)
+	}
+	if h.Interval <= 0 {
+		return nil, fmt.Errorf("[histogram] interval must be greater than 0")
+	}
+
+	interval, offset := h.Interval, h.Offset
+	key := func(n float64) aggKey {
+		n = math.Floor((n-offset)/interval)*interval + offset
+		return aggKey{name: formatFloatKey(n), key: n}
+	}
+	src := search.Field(h.Field)
+	keys := func(d *search.DocumentMatch) []aggKey {
+		var keys []aggKey
+		for _, n := range src.Numbers(d) {
+			keys = append(keys, key(n))
+		}
+		return keys
+	}
+	next := func(k aggKey) aggKey { return key(k.key.(float64) + interval) }
+
+	return newBucketAggregation(h.Field, keys, sub, histogramFinish(h.MinDocCount, next)), nil
+}
+
+func dateHistogramAggregation(h *v1.DateHistogramAggregation, types aggFields, sub map[string]search.Aggregation) (search.Aggregation, error) {
+	field := h.Field
+	if field == "" {
+		field = "@timestamp"
+	}
+	if typ := types.typ(field); typ != fieldTypeDate && typ != "" {
+		return nil, fmt.Errorf("[date_histogram] field [%s] is not a date field", field)
+	}
+
+	interval, err := parseDateInterval(h.Interval)
+	if err != nil {
+		return nil, err
+	}
+	loc := time.UTC
+	if h.TimeZone != "" {
+		if loc, err = dslLocation(h.TimeZone); err != nil {
+			return nil, err
+		}
+	}
+
+	key := func(t time.Time) aggKey {
+		start := interval.floor(t.In(loc))
+		return aggKey{name: strconv.FormatInt(start.UnixNano(), 10), key: start.UnixNano() / int64(time.Millisecond), asString: start.Format(time.RFC3339)}
+	}
+	src := search.Field(field)
+	keys := func(d *search.DocumentMatch) []aggKey {
+		var keys []aggKey
+		for _, t := range src.Dates(d) {
+			keys = append(keys, key(t))
+		}
+		return keys
+	}
+	next := func(k aggKey) aggKey {
+		start := time.Unix(0, k.key.(int64)*int64(time.Millisecond)).In(loc)
+		return key(interval.next(start))
+	}
+
+	return newBucketAggregation(field, keys, sub, histogramFinish(h.MinDocCount, next)), nil
+}
+
+// histogramFinish sorts the buckets by key. With a min_doc_count of 0, empty buckets fill the gaps between
+// the first and the last bucket, up to maxBuckets, else the buckets with fewer documents are left out.
+func histogramFinish(minDocCount int, next func(aggKey) aggKey) func(*bucketCalculator) {
+	return func(c *bucketCalculator) {
+		sort.Slice(c.list, func(i, j int) bool { return c.list[i].less(c.list[j]) })
+		if minDocCount > 0 {
+			c.keep(func(b *keyedBucket) bool { return b.Count() >= uint64(minDocCount) })
+			return
+		}
+		if len(c.list) < 2 {
+			return
+		}
+
+		last := c.list[len(c.list)-1]
+		filled := []*keyedBucket{c.list[0]}
+		for k := next(c.list[0].aggKey); k.less(last.aggKey) && len(filled) < maxBuckets; k = next(k) {
+			if b, ok := c.buckets[k.name]; ok {
+				filled = append(filled, b)
+			} else {
+				filled = append(filled, c.newBucket(k))
+			}
+		}
+		if len(filled) == maxBuckets {
+			return // too many gaps, keep the buckets with documents
+		}
+		c.list = append(filled, last)
+	}
+}
+
+// dateInterval is a calendar interval, which follows the calendar of a time zone, or a fixed interval.
+type dateInterval struct {
+	unit  string // minute, hour, day, week, month, quarter or year
+	fixed time.Duration
+}
+
+var fixedIntervalRegexp = regexp.MustCompile(`^(\d+)(ms|s|m|h|d)$`)
+
+func parseDateInterval(s string) (dateInterval, error) {
+	switch s {
+	case "minute", "1m":
+		return dateInterval{unit: "minute"}, nil
+	case "hour", "1h":
+		return dateInterval{unit: "hour"}, nil
+	case "day", "1d":
+		return dateInterval{unit: "day"}, nil
+	case "week", "1w":
+		return dateInterval{unit: "week"}, nil
+	case "month", "1M":
+		return dateInterval{unit: "month"}, nil
+	case "quarter", "1q":
+		return dateInterval{unit: "quarter"}, nil
+	case "year", "1y":
+		return dateInterval{unit: "year"}, nil
+	case "":
+		return dateInterval{}, fmt.Errorf("[date_histogram] interval is required")
+	}
+
+	m := fixedIntervalRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return dateInterval{}, fmt.Errorf("[date_histogram] invalid interval [%s]", s)
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil || n == 0 {
+		return dateInterval{}, fmt.Errorf("[date_histogram] invalid interval [%s]", s)
+	}
+	unit := map[string]time.Duration{"ms": time.Millisecond, "s": time.Second, "m": time.Minute, "h": time.Hour, "d": 24 * time.Hour}[m[2]]
+	return dateInterval{fixed: time.Duration(n) * unit}, nil
+}
+
+// floor returns the start of the interval of t, in the location of t.
+func (iv dateInterval) floor(t time.Time) time.Time {
+	y, mo, d := t.Date()
+	loc := t.Location()
+	switch iv.unit {
+	case "minute":
+		return time.Date(y, mo, d, t.Hour(), t.Minute(), 0, 0, loc)
+	case "hour":
+		return time.Date(y, mo, d, t.Hour(), 0, 0, 0, loc)
+	case "day":
+		return time.Date(y, mo, d, 0, 0, 0, 0, loc)
+	case "week": // weeks start on Monday
+		return time.Date(y, mo, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, loc)
+	case "month":
+		return time.Date(y, mo, 1, 0, 0, 0, 0, loc)
+	case "quarter":
+		return time.Date(y, (mo-1)/3*3+1, 1, 0, 0, 0, 0, loc)
+	case "year":
+		return time.Date(y, 1, 1, 0, 0, 0, 0, loc)
+	}
+
+	// Fixed intervals are counted from the epoch in local time
+	_, offset := t.Zone()
+	local := t.UnixNano() + int64(offset)*int64(time.Second)
+	start := local - local%int64(iv.fixed)
+	if local%int64(iv.fixed) < 0 {
+		start -= int64(iv.fixed)
+	}
+	return time.Unix(0, start-int64(offset)*int64(time.Second)).In(loc)
+}
+
+// next returns the start of the interval after the interval starting at t.
+func (iv dateInterval) next(t time.Time) time.Time {
+	y, mo, d := t.Date()
+	loc := t.Location()
+	switch iv.unit {
+	case "minute":
+		return t.Add(time.Minute)
+	case "hour":
+		return t.Add(time.Hour)
+	case "day":
+		return time.Date(y, mo, d+1, 0, 0, 0, 0, loc)
+	case "week":
+		return time.Date(y, mo, d+7, 0, 0, 0, 0, loc)
+	case "month":
+		return time.Date(y, mo+1, 1, 0, 0, 0, 0, loc)
+	case "quarter":
+		return time.Date(y, mo+3, 1, 0, 0, 0, 0, loc)
+	case "year":
+		return time.Date(y+1, 1, 1, 0, 0, 0, 0, loc)
+	}
+	return iv.floor(t.Add(iv.fixed))
+}
+
+func metricAggregation(kind string, agg v1.AggregationRequest, types aggFields) (search.Aggregation, error) {
+	var field string
+	switch kind {
+	case "min":
+		field = agg.Min.Field
+	case "max":
+		field = agg.Max.Field
+	case "avg":
+		field = agg.Avg.Field
+	case "sum":
+		field = agg.Sum.Field
+	case "cardinality":
+		field = agg.Cardinality.Field
+	case "percentiles":
+		field = agg.Percentiles.Field
+	}
+	if field == "" {
+		return nil, fmt.Errorf("[%s] field is required", kind)
+	}
+	typ := types.typ(field)
+
+	if kind == "cardinality" {
+		switch typ {
+		case fieldTypeText, fieldTypeStored:
+			return nil, fmt.Errorf("[cardinality] %s field [%s] cannot be aggregated, map it as keyword", typ, field)
+		case fieldTypeNumeric, fieldTypeDate:
+			return aggregations.Cardinality(search.FilterText(search.Field(field), isFullPrecision)), nil
+		}
+		return aggregations.Cardinality(search.Field(field)), nil
+	}
+
+	var src search.NumericValuesSource
+	switch typ {
+	case fieldTypeNumeric, "":
+		src = search.Field(field)
+	case fieldTypeDate:
+		src = dateMillisSource(field)
+	default:
+		return nil, fmt.Errorf("[%s] field [%s] is not a numeric or date field", kind, field)
+	}
+
+	switch kind {
+	case "min":
+		return aggregations.Min(src), nil
+	case "max":
+		return aggregations.Max(src), nil
+	case "avg":
+		return aggregations.Avg(src), nil
+	case "sum":
+		return aggregations.Sum(src), nil
+	}
+
+	for _, p := range agg.Percentiles.Percents {
+		if p < 0 || p > 100 {
+			return nil, fmt.Errorf("[percentiles] percents must be between 0 and 100")
+		}
+	}
+	return aggregations.Quantiles(src), nil
+}
+
+// isFullPrecision reports whether the term is the full precision term of a number or a date. The other terms
+// of numeric fields are the shifted terms of range queries.
+func isFullPrecision(term []byte) bool {
+	shift, err := numeric.PrefixCoded(term).Shift()
+	return err == nil && shift == 0
+}
+
+// dateMillisSource is the values of a date field in milliseconds since the epoch.
+type dateMillisSource string
+
+func (s dateMillisSource) Fields() []string {
+	return []string{string(s)}
+}
+
+func (s dateMillisSource) Numbers(d *search.DocumentMatch) []float64 {
+	dates := search.Field(string(s)).Dates(d)
+	numbers := make([]float64, len(dates))
+	for i, t := range dates {
+		numbers[i] = float64(t.UnixNano() / int64(time.Millisecond))
+	}
+	return numbers
+}
+
+// termKeys returns the keys of the values of the field in a document, by field type.
+func termKeys(field, typ string) (func(d *search.DocumentMatch) []aggKey, error) {
+	src := search.Field(field)
+	switch typ {
+	case fieldTypeText, fieldTypeStored:
+		return nil, fmt.Errorf("[terms] %s field [%s] cannot be aggregated, map it as keyword", typ, field)
+	case fieldTypeNumeric:
+		return func(d *search.DocumentMatch) []aggKey {
+			var keys []aggKey
+			for _, n := range src.Numbers(d) {
+				keys = append(keys, aggKey{name: formatFloatKey(n), key: n})
+			}
+			return keys
+		}, nil
+	case fieldTypeDate:
+		return func(d *search.DocumentMatch) []aggKey {
+			var keys []aggKey
+			for _, t := range src.Dates(d) {
+				keys = append(keys, aggKey{
+					name:     strconv.FormatInt(t.UnixNano(), 10),
+					key:      t.UnixNano() / int64(time.Millisecond),
+					asString: t.UTC().Format(time.RFC3339Nano),
+				})
+			}
+			return keys
+		}, nil
+	}
+
+	return func(d *search.DocumentMatch) []aggKey {
+		var keys []aggKey
+		for _, term := range src.Values(d) {
+			keys = append(keys, aggKey{name: string(term), key: string(term)})
+		}
+		return keys
+	}, nil
+}
+
+// aggKey is the key of a bucket. Its name is unique in the aggregation.
+type aggKey struct {
+	name     string
+	key      interface{} // a string, a float64, or int64 milliseconds since the epoch for dates
+	asString string
+}
+
+func (k aggKey) less(other aggKey) bool {
+	switch v := k.key.(type) {
+	case float64:
+		return v < other.key.(float64)
+	case int64:
+		return v < other.key.(int64)
+	}
+	return k.name < other.name
+}
+
+// bucketAggregation groups the documents in buckets by the keys of the values of a field.
+// It is the aggregation of terms, histogram and date_histogram.
+type bucketAggregation struct {
+	field        string
+	keys         func(d *search.DocumentMatch) []aggKey
+	finish       func(c *bucketCalculator) // sorts and trims the buckets
+	aggregations map[string]search.Aggregation
+}
+
+func newBucketAggregation(field string, keys func(d *search.DocumentMatch) []aggKey, sub map[string]search.Aggregation, finish func(c *bucketCalculator)) *bucketAggregation {
+	sub["count"] = aggregations.CountMatches()
+	return &bucketAggregation{field: field, keys: keys, finish: finish, aggregations: sub}
+}
+
+func (a *bucketAggregation) Fields() []string {
+	fields := []string{a.field}
+	for _, agg := range a.aggregations {
+		fields = append(fields, agg.Fields()...)
+	}
+	return fields
+}
+
+func (a *bucketAggregation) Calculator() search.Calculator {
+	return &bucketCalculator{agg: a, buckets: make(map[string]*keyedBucket)}
+}
+
+type bucketCalculator struct {
+	agg     *bucketAggregation
+	buckets map[string]*keyedBucket
+	list    []*keyedBucket
+	other   int // documents of the buckets trimmed from the list
+}
+
+type keyedBucket struct {
+	aggKey
+	*search.Bucket
+}
+
+func (b *keyedBucket) less(other *keyedBucket) bool {
+	return b.aggKey.less(other.aggKey)
+}
+
+func (c *bucketCalculator) newBucket(k aggKey) *keyedBucket {
+	b := &keyedBucket{aggKey: k, Bucket: search.NewBucket(k.name, c.agg.aggregations)}
+	c.buckets[k.name] = b
+	return b
+}
+
+// Consume adds the document to the bucket of each of its keys, once per bucket.
+func (c *bucketCalculator) Consume(d *search.DocumentMatch) {
+	keys := c.agg.keys(d)
+	for i, k := range keys {
+		duplicate := false
+		for _, prev := range keys[:i] {
+			if prev.name == k.name {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+
+		b, ok := c.buckets[k.name]
+		if !ok {
+			b = c.newBucket(k)
+			c.list = append(c.list, b)
+		}
+		b.Consume(d)
+	}
+}
+
+func (c *bucketCalculator) Merge(other search.Calculator) {
+	o, ok := other.(*bucketCalculator)
+	if !ok {
+		return
+	}
+	for _, ob := range o.list {
+		if b, ok := c.buckets[ob.name]; ok {
+			b.Merge(ob.Bucket)
+		} else {
+			c.buckets[ob.name] = ob
+			c.list = append(c.list, ob)
+		}
+	}
+	c.other += o.other
+}
+
+func (c *bucketCalculator) Finish() {
+	c.agg.finish(c)
+	for _, b := range c.list {
+		b.Finish()
+	}
+}
+
+func (c *bucketCalculator) Buckets() []*search.Bucket {
+	buckets := make([]*search.Bucket, len(c.list))
+	for i, b := range c.list {
+		buckets[i] = b.Bucket
+	}
+	return buckets
+}
+
+// keep keeps the buckets of the list for which fn is true.
+func (c *bucketCalculator) keep(fn func(b *keyedBucket) bool) {
+	kept := c.list[:0]
+	for _, b := range c.list {
+		if fn(b) {
+			kept = append(kept, b)
+		}
+	}
+	c.list = kept
+}
+
+// aggregationResult returns the result of the aggregation from its calculator.
+func aggregationResult(agg v1.AggregationRequest, types aggFields, calc search.Calculator) v1.AggregationResponse {
+	var resp v1.AggregationResponse
+	switch calc := calc.(type) {
+	case *bucketCalculator:
+		for _, b := range calc.list {
+			resp.Buckets = append(resp.Buckets, v1.AggregationBucket{
+				Key:          b.key,
+				KeyAsString:  b.asString,
+				DocCount:     b.Count(),
+				Aggregations: bucketResults(agg.Aggs, types, b.Bucket),
+			})
+		}
+		resp.SumOtherDocCount = calc.other
+	case search.BucketCalculator: // range
+		for i, b := range calc.Buckets() {
+			bucket := v1.AggregationBucket{Key: b.Name(), DocCount: b.Count(), Aggregations: bucketResults(agg.Aggs, types, b)}
+			if i < len(agg.Range.Ranges) {
+				r := agg.Range.Ranges[i]
+				if types.typ(agg.Range.Field) == fieldTypeDate {
+					from, to, _ := dateRange(r)
+					if r.From != nil {
+						bucket.From = from.UnixNano() / int64(time.Millisecond)
+					}
+					if r.To != nil {
+						bucket.To = to.UnixNano() / int64(time.Millisecond)
+					}
+				} else {
+					from, to, _ := numericRange(r)
+					if r.From != nil {
+						bucket.From = from
+					}
+					if r.To != nil {
+						bucket.To = to
+					}
+				}
+			}
+			resp.Buckets = append(resp.Buckets, bucket)
+		}
+	case *aggregations.QuantilesCalculator:
+		percents := agg.Percentiles.Percents
+		if len(percents) == 0 {
+			percents = defaultPercents
+		}
+		resp.Values = make(map[string]float64, len(percents))
+		for _, p := range percents {
+			if v, err := calc.Quantile(p / 100); err == nil && !math.IsNaN(v) {
+				resp.Values[formatFloatKey(p)] = v
+			}
+		}
+	case search.MetricCalculator:
+		v := calc.Value()
+		if math.IsNaN(v) || math.IsInf(v, 0) { // min, max or avg of no values
+			return resp
+		}
+		resp.Value = &v
+		if agg.Cardinality == nil && types.typ(metricField(agg)) == fieldTypeDate {
+			resp.ValueAsString = time.Unix(0, int64(v)*int64(time.Millisecond)).UTC().Format(time.RFC3339Nano)
+		}
+	}
+
+	return resp
+}
+
+func bucketResults(aggs map[string]v1.AggregationRequest, types aggFields, bucket *search.Bucket) map[string]v1.AggregationResponse {
+	if len(aggs) == 0 {
+		return nil
+	}
+
+	resp := make(map[string]v1.AggregationResponse, len(aggs))
+	for name, agg := range aggs {
+		resp[name] = aggregationResult(agg, types, bucket.Aggregation(aggPrefix+name))
+	}
+	return resp
+}
+
+func metricField(agg v1.AggregationRequest) string {
+	for _, m := range []*v1.MetricAggregation{agg.Min, agg.Max, agg.Avg, agg.Sum} {
+		if m != nil {
+			return m.Field
+		}
+	}
+	return ""
+}
+
+// formatFloatKey formats a number like the keys of Elasticsearch, e.g. 50.0 or 99.9.
+func formatFloatKey(n float64) string {
+	s := strconv.FormatFloat(n, 'f', -1, 64)
+	if !strings.ContainsAny(s, ".eEnI") {
+		s += ".0"
+	}
+	return s
+}
@@ -0,0 +1,153 @@
+package uquery
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/blugelabs/bluge"
+	v1 "github.com/prabhatsharma/zinc/pkg/meta/v1"
+)
+
+func TestAggregations(t *testing.T) {
+	writer, err := bluge.OpenWriter(bluge.InMemoryOnlyConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	// The fields are indexed like the fields of the mapping of an index, see core.Index.newBlugeField
+	day := time.Date(2024, time.March, 10, 0, 0, 0, 0, time.UTC)
+	docs := []struct {
+		status string
+		bytes  float64
+		time   time.Time
+	}{
+		{"ok", 120, day.Add(1 * time.Hour)},
+		{"ok", 80, day.Add(2 * time.Hour)},
+		{"error", 450, day.Add(3 * time.Hour)},
+		{"ok", 130, day.Add(26 * time.Hour)},
+		{"warn", 90, day.Add(74 * time.Hour)},
+	}
+	batch := bluge.NewBatch()
+	for i, d := range docs {
+		doc := bluge.NewDocument(string(rune('a' + i))).
+			AddField(bluge.NewKeywordField("status", d.status).Aggregatable()).
+			AddField(bluge.NewNumericField("bytes", d.bytes)).
+			AddField(bluge.NewDateTimeField("@timestamp", d.time).Sortable())
+		batch.Update(doc.ID(), doc)
+	}
+	if err := writer.Batch(batch); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := writer.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	fieldTypes := map[string]string{"status": "keyword", "bytes": "numeric"}
+
+	tests := []struct {
+		name string
+		agg  string
+		want string
+	}{
+		{
+			name: "terms",
+			agg:  `{"terms": {"field": "status"}}`,
+			want: `{"buckets":[{"key":"ok","doc_count":3},{"key":"error","doc_count":1},{"key":"warn","doc_count":1}]}`,
+		},
+		{
+			name: "terms by key with a size",
+			agg:  `{"terms": {"field": "status", "size": 2, "order": {"_key": "desc"}}}`,
+			want: `{"buckets":[{"key":"warn","doc_count":1},{"key":"ok","doc_count":3}],"sum_other_doc_count":1}`,
+		},
+		{
+			name: "terms with a minimum count",
+			agg:  `{"terms": {"field": "status", "min_doc_count": 2}}`,
+			want: `{"buckets":[{"key":"ok","doc_count":3}]}`,
+		},
+		{
+			name: "terms with a nested metric",
+			agg:  `{"terms": {"field": "status", "size": 1}, "aggs": {"avg_bytes": {"avg": {"field": "bytes"}}}}`,
+			want: `{"buckets":[{"key":"ok","doc_count":3,"aggregations":{"avg_bytes":{"value":110}}}],"sum_other_doc_count":2}`,
+		},
+		{
+			name: "histogram filling the gaps",
+			agg:  `{"histogram": {"field": "bytes", "interval": 100}}`,
+			want: `{"buckets":[{"key":0,"doc_count":2},{"key":100,"doc_count":2},{"key":200,"doc_count":0},{"key":300,"doc_count":0},{"key":400,"doc_count":1}]}`,
+		},
+		{
+			name: "histogram with a minimum count and an offset",
+			agg:  `{"histogram": {"field": "bytes", "interval": 100, "offset": 50, "min_doc_count": 1}}`,
+			want: `{"buckets":[{"key":50,"doc_count":4},{"key":450,"doc_count":1}]}`,
+		},
+		{
+			name: "date histogram",
+			agg:  `{"date_histogram": {"interval": "day"}}`,
+			want: `{"buckets":[` +
+				`{"key":1710028800000,"key_as_string":"2024-03-10T00:00:00Z","doc_count":3},` +
+				`{"key":1710115200000,"key_as_string":"2024-03-11T00:00:00Z","doc_count":1},` +
+				`{"key":1710201600000,"key_as_string":"2024-03-12T00:00:00Z","doc_count":0},` +
+				`{"key":1710288000000,"key_as_string":"2024-03-13T00:00:00Z","doc_count":1}]}`,
+		},
+		{
+			name: "date histogram in a time zone",
+			agg:  `{"date_histogram": {"field": "@timestamp", "interval": "1d", "time_zone": "-02:00", "min_doc_count": 1}}`,
+			want: `{"buckets":[` +
+				`{"key":1709949600000,"key_as_string":"2024-03-09T00:00:00-02:00","doc_count":1},` +
+				`{"key":1710036000000,"key_as_string":"2024-03-10T00:00:00-02:00","doc_count":2},` +
+				`{"key":1710122400000,"key_as_string":"2024-03-11T00:00:00-02:00","doc_count":1},` +
+				`{"key":1710295200000,"key_as_string":"2024-03-13T00:00:00-02:00","doc_count":1}]}`,
+		},
+		{
+			name: "range",
+			agg:  `{"range": {"field": "bytes", "ranges": [{"to": 100}, {"from": 100, "to": 200}, {"key": "large", "from": 200}]}}`,
+			want: `{"buckets":[{"key":"*-100.0","to":100,"doc_count":2},{"key":"100.0-200.0","from":100,"to":200,"doc_count":2},{"key":"large","from":200,"doc_count":1}]}`,
+		},
+		{
+			name: "metrics",
+			agg:  `{"max": {"field": "bytes"}}`,
+			want: `{"value":450}`,
+		},
+		{
+			name: "cardinality",
+			agg:  `{"cardinality": {"field": "status"}}`,
+			want: `{"value":3}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var agg v1.AggregationRequest
+			if err := json.Unmarshal([]byte(tt.agg), &agg); err != nil {
+				t.Fatal(err)
+			}
+			aggs := map[string]v1.AggregationRequest{"agg": agg}
+
+			request := bluge.NewTopNSearch(0, bluge.NewMatchAllQuery()).WithStandardAggregations()
+			if err := AddAggregations(request, aggs, fieldTypes); err != nil {
+				t.Fatalf("AddAggregations() error = %v", err)
+			}
+			matches, err := reader.Search(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for match, err := matches.Next(); match != nil || err != nil; match, err = matches.Next() {
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := json.Marshal(AggregationResults(aggs, fieldTypes, matches.Aggregations())["agg"])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("aggregation = %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
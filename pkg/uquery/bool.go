@@ -0,0 +1,145 @@
+package uquery
+
+import (
+	"fmt"
+
+	"github.com/blugelabs/bluge"
+	v1 "github.com/prabhatsharma/zinc/pkg/meta/v1"
+)
+
+// BoolQuery combines the clauses of the bool query of the ZincQuery, within start_time and end_time if given.
+// Documents are scored by their must and should clauses, filter clauses only select them.
+func BoolQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
+	if iQuery.Bool == nil {
+		return nil, fmt.Errorf("search_type bool requires a bool query")
+	}
+
+	boolQuery, err := compileBool(iQuery.Bool, iQuery.FieldAnalyzers)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := withTimeRange(iQuery, boolQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	searchRequest := buildRequest(iQuery, query)
+
+	return searchRequest, nil
+}
+
+// compileBool compiles the bool query to a bluge.BooleanQuery, nested bool queries to nested BooleanQuery.
+// A bool query without clauses matches all documents.
+func compileBool(b *v1.BoolQuery, fieldAnalyzers map[string]string) (bluge.Query, error) {
+	clauses := make(map[string][]bluge.Query)
+	for _, occur := range []struct {
+		name    string
+		clauses []v1.QueryClause
+	}{
+		{"must", b.Must},
+		{"filter", b.Filter},
+		{"should", b.Should},
+		{"must_not", b.MustNot},
+	} {
+		for i, clause := range occur.clauses {
+			q, err := compileClause(clause, fieldAnalyzers)
+			if err != nil {
+				return nil, fmt.Errorf("[bool] %s clause %d: %v", occur.name, i, err)
+			}
+			clauses[occur.name] = append(clauses[occur.name], q)
+		}
+	}
+
+	return combineBool(clauses, b.MinimumShouldMatch)
+}
+
+// combineBool combines the compiled clauses of a bool query by occurrence, must, filter, should and must_not, to a
+// bluge.BooleanQuery. Filter clauses must match like must clauses but do not add to the score. minShould is the
+// minimum_should_match of the query, nil if not given. Without clauses the query matches all documents.
+func combineBool(clauses map[string][]bluge.Query, minShould interface{}) (bluge.Query, error) {
+	if len(clauses["must"])+len(clauses["filter"])+len(clauses["should"])+len(clauses["must_not"]) == 0 {
+		return bluge.NewMatchAllQuery(), nil
+	}
+
+	query := bluge.NewBooleanQuery()
+	query.AddMust(clauses["must"]...)
+	for _, q := range clauses["filter"] {
+		query.AddMust(filterQuery(q))
+	}
+	query.AddShould(clauses["should"]...)
+	query.AddMustNot(clauses["must_not"]...)
+
+	if minShould != nil {
+		min, err := minimumShouldMatch(minShould, len(clauses["should"]))
+		if err != nil {
+			return nil, err
+		}
+		query.SetMinShould(min)
+	}
+
+	return query, nil
+}
+
+// filterQuery returns a query that matches the documents that match all of the queries, without adding to their
+// score, as bluge has no filter context.
+func filterQuery(queries ...bluge.Query) bluge.Query {
+	return bluge.NewBooleanQuery().AddMust(queries...).SetBoost(0)
+}
+
+// compileClause compiles a clause of a bool query. The clause matches like the search of its search type,
+// without start_time and end_time, except for daterange which limits its field, @timestamp by default.
+// matchall and alldocuments both match all documents.
+func compileClause(clause v1.QueryClause, fieldAnalyzers map[string]string) (bluge.Query, error) {
+	if clause.Bool != nil {
+		if clause.SearchType != "" && clause.SearchType != "bool" {
+			return nil, fmt.Errorf("a clause has either a search_type or a bool query, not both")
+		}
+		return compileBool(clause.Bool, fieldAnalyzers)
+	}
+
+	params := clause.Query
+	field := params.Field
+	if field == "" {
+		field = "_all"
+	}
+
+	switch clause.SearchType {
+	case "alldocuments", "matchall":
+		return bluge.NewMatchAllQuery(), nil
+	case "wildcard":
+		return bluge.NewWildcardQuery(params.Term).SetField(field), nil
+	case "fuzzy":
+		return bluge.NewFuzzyQuery(params.Term).SetField(field), nil
+	case "term":
+		return bluge.NewTermQuery(params.Term).SetField(field), nil
+	case "prefix":
+		return bluge.NewPrefixQuery(params.Term).SetField(field), nil
+	case "multiphrase":
+		return bluge.NewMultiPhraseQuery(params.Terms).SetField(field), nil
+	case "daterange":
+		if params.Field == "" {
+			field = "@timestamp"
+		}
+		dateQuery, err := timeRangeQuery(params, field)
+		if err != nil || dateQuery != nil {
+			return dateQuery, err
+		}
+		return bluge.NewMatchAllQuery(), nil
+	case "match", "matchphrase":
+		a, err := fieldAnalyzer(v1.ZincQuery{Query: params, FieldAnalyzers: fieldAnalyzers}, field)
+		if err != nil {
+			return nil, err
+		}
+		if clause.SearchType == "match" {
+			return bluge.NewMatchQuery(params.Term).SetField(field).SetAnalyzer(a), nil
+		}
+		return bluge.NewMatchPhraseQuery(params.Term).SetField(field).SetAnalyzer(a), nil
+	case "querystring":
+		return parseQueryString(params.Term, params.Analyzer, fieldAnalyzers)
+	case "", "bool":
+		return nil, fmt.Errorf("a clause requires a search_type or a bool query")
+	}
+
+	return nil, fmt.Errorf("unknown search_type [%s]", clause.SearchType)
+}
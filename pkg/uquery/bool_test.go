@@ -0,0 +1,163 @@
+package uquery
+
+import (
+	"testing"
+
+	"github.com/blugelabs/bluge"
+	v1 "github.com/prabhatsharma/zinc/pkg/meta/v1"
+)
+
+func clause(searchType, field, term string) v1.QueryClause {
+	return v1.QueryClause{SearchType: searchType, Query: v1.QueryParams{Field: field, Term: term}}
+}
+
+func TestCompileBool(t *testing.T) {
+	tests := []struct {
+		name      string
+		bool      v1.BoolQuery
+		want      string
+		minShould int
+		wantErr   string
+	}{
+		{
+			name: "no clauses",
+			want: "*",
+		},
+		{
+			name: "must, should and must_not",
+			bool: v1.BoolQuery{
+				Must:    []v1.QueryClause{clause("term", "a", "x")},
+				Should:  []v1.QueryClause{clause("prefix", "b", "y"), clause("fuzzy", "b", "z")},
+				MustNot: []v1.QueryClause{clause("wildcard", "c", "w*")},
+			},
+			want: "(+a:x b:y* b:z~1 -c:w*)",
+		},
+		{
+			name: "filter",
+			bool: v1.BoolQuery{Filter: []v1.QueryClause{clause("term", "a", "x"), clause("matchall", "", "")}},
+			want: "(+(+a:x) +(+*))",
+		},
+		{
+			name: "default field",
+			bool: v1.BoolQuery{Must: []v1.QueryClause{clause("term", "", "x")}},
+			want: "(+_all:x)",
+		},
+		{
+			name: "nested bool",
+			bool: v1.BoolQuery{
+				Must: []v1.QueryClause{
+					{Bool: &v1.BoolQuery{Should: []v1.QueryClause{clause("term", "a", "x"), clause("term", "a", "y")}}},
+					{SearchType: "bool", Bool: &v1.BoolQuery{MustNot: []v1.QueryClause{clause("term", "b", "z")}}},
+				},
+			},
+			want: "(+(a:x a:y) +(-b:z))",
+		},
+		{
+			name: "minimum_should_match count",
+			bool: v1.BoolQuery{
+				Should:             []v1.QueryClause{clause("term", "a", "x"), clause("term", "a", "y"), clause("term", "a", "z")},
+				MinimumShouldMatch: float64(2),
+			},
+			want:      "(a:x a:y a:z)",
+			minShould: 2,
+		},
+		{
+			name: "minimum_should_match negative count",
+			bool: v1.BoolQuery{
+				Should:             []v1.QueryClause{clause("term", "a", "x"), clause("term", "a", "y"), clause("term", "a", "z")},
+				MinimumShouldMatch: "-1",
+			},
+			want:      "(a:x a:y a:z)",
+			minShould: 2,
+		},
+		{
+			name: "minimum_should_match percentage",
+			bool: v1.BoolQuery{
+				Should:             []v1.QueryClause{clause("term", "a", "x"), clause("term", "a", "y"), clause("term", "a", "z")},
+				MinimumShouldMatch: "50%",
+			},
+			want:      "(a:x a:y a:z)",
+			minShould: 1,
+		},
+		{
+			name: "minimum_should_match above the should clauses",
+			bool: v1.BoolQuery{
+				Must:               []v1.QueryClause{clause("term", "a", "x")},
+				Should:             []v1.QueryClause{clause("term", "a", "y")},
+				MinimumShouldMatch: float64(5),
+			},
+			want:      "(+a:x a:y)",
+			minShould: 1,
+		},
+		{
+			name:    "invalid minimum_should_match",
+			bool:    v1.BoolQuery{Should: []v1.QueryClause{clause("term", "a", "x")}, MinimumShouldMatch: "most"},
+			wantErr: "invalid minimum_should_match [most]",
+		},
+		{
+			name:    "clause without search_type",
+			bool:    v1.BoolQuery{Must: []v1.QueryClause{{}}},
+			wantErr: "[bool] must clause 0: a clause requires a search_type or a bool query",
+		},
+		{
+			name:    "unknown search_type",
+			bool:    v1.BoolQuery{Filter: []v1.QueryClause{clause("term", "a", "x"), clause("near", "a", "x")}},
+			wantErr: "[bool] filter clause 1: unknown search_type [near]",
+		},
+		{
+			name:    "search_type and bool",
+			bool:    v1.BoolQuery{Should: []v1.QueryClause{{SearchType: "term", Bool: &v1.BoolQuery{}}}},
+			wantErr: "[bool] should clause 0: a clause has either a search_type or a bool query, not both",
+		},
+		{
+			name: "error in a nested bool",
+			bool: v1.BoolQuery{
+				MustNot: []v1.QueryClause{{Bool: &v1.BoolQuery{Must: []v1.QueryClause{clause("term", "a", "x"), {}}}}},
+			},
+			wantErr: "[bool] must_not clause 0: [bool] must clause 1: a clause requires a search_type or a bool query",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := compileBool(&tt.bool, nil)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("compileBool() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("compileBool() error = %v", err)
+			}
+			if s := describeQuery(got); s != tt.want {
+				t.Errorf("compileBool() = %s, want %s", s, tt.want)
+			}
+			if b, ok := got.(*bluge.BooleanQuery); ok && b.MinShould() != tt.minShould {
+				t.Errorf("MinShould() = %d, want %d", b.MinShould(), tt.minShould)
+			}
+		})
+	}
+}
+
+func TestCompileBoolFilterDoesNotScore(t *testing.T) {
+	b := &v1.BoolQuery{
+		Must:   []v1.QueryClause{clause("term", "a", "x")},
+		Filter: []v1.QueryClause{clause("term", "b", "y")},
+	}
+	got, err := compileBool(b, nil)
+	if err != nil {
+		t.Fatalf("compileBool() error = %v", err)
+	}
+
+	musts := got.(*bluge.BooleanQuery).Musts()
+	if len(musts) != 2 {
+		t.Fatalf("compileBool() has %d must clauses, want 2", len(musts))
+	}
+	if boost := musts[0].(*bluge.TermQuery).Boost(); boost != 1 {
+		t.Errorf("must clause boost = %v, want 1", boost)
+	}
+	if boost := musts[1].(*bluge.BooleanQuery).Boost(); boost != 0 {
+		t.Errorf("filter clause boost = %v, want 0", boost)
+	}
+}
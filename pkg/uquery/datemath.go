@@ -0,0 +1,171 @@
+package uquery
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blugelabs/bluge"
+	v1 "github.com/prabhatsharma/zinc/pkg/meta/v1"
+	"github.com/prabhatsharma/zinc/pkg/zutil"
+)
+
+// defaultTimeZone returns the time zone of the dates and the date math of searches without a time_zone.
+func defaultTimeZone() string {
+	return zutil.GetEnv("ZINC_TIME_ZONE", "UTC")
+}
+
+// withTimeRange limits the query to the documents with the time field of the ZincQuery, @timestamp by default,
+// from start_time up to end_time. The query is not limited without start_time and end_time.
+func withTimeRange(iQuery v1.ZincQuery, query bluge.Query) (bluge.Query, error) {
+	field := iQuery.Query.TimeField
+	if field == "" {
+		field = "@timestamp"
+	}
+	if typ, ok := iQuery.FieldTypes[field]; ok && typ != fieldTypeDate && typ != fieldTypeTime {
+		return nil, fmt.Errorf("time_field [%s] is not a date field", field)
+	}
+
+	dateQuery, err := timeRangeQuery(iQuery.Query, field)
+	if err != nil || dateQuery == nil {
+		return query, err
+	}
+	return bluge.NewBooleanQuery().AddMust(dateQuery).AddMust(query), nil
+}
+
+// timeRangeQuery returns the query of the documents with the date field from start_time up to end_time of the
+// params, nil without start_time and end_time. Either can be left out for an open range.
+func timeRangeQuery(params v1.QueryParams, field string) (bluge.Query, error) {
+	if params.StartTime == "" && params.EndTime == "" {
+		return nil, nil
+	}
+
+	tz := params.TimeZone
+	if tz == "" {
+		tz = defaultTimeZone()
+	}
+	loc, err := dslLocation(tz)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var start, end time.Time
+	if params.StartTime != "" {
+		if start, err = parseDateMath(params.StartTime, now, loc); err != nil {
+			return nil, fmt.Errorf("start_time: %v", err)
+		}
+	}
+	if params.EndTime != "" {
+		if end, err = parseDateMath(params.EndTime, now, loc); err != nil {
+			return nil, fmt.Errorf("end_time: %v", err)
+		}
+	}
+
+	return bluge.NewDateRangeQuery(start, end).SetField(field), nil
+}
+
+var (
+	dateMathRegexp    = regexp.MustCompile(`^([+-]\d+|/)([yMwdhHms])`)
+	isoDurationRegexp = regexp.MustCompile(`^P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+)
+
+// parseDateMath returns the time of a date, a date math expression or an ISO 8601 duration, in the location.
+//
+// Date math starts from now or from a date followed by ||, and adds (+1d), subtracts (-15m) or rounds down to
+// (/d) units of y (years), M (months), w (weeks), d (days), h or H (hours), m (minutes) and s (seconds), e.g.
+// now-1d/d is the start of yesterday. A duration like PT15M or P1D is the time that long before now.
+func parseDateMath(s string, now time.Time, loc *time.Location) (time.Time, error) {
+	if strings.HasPrefix(s, "P") {
+		d, err := parseISODuration(s)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return d(now.In(loc)), nil
+	}
+
+	var t time.Time
+	var expr string
+	switch {
+	case strings.HasPrefix(s, "now"):
+		t, expr = now.In(loc), s[len("now"):]
+	case strings.Contains(s, "||"):
+		parts := strings.SplitN(s, "||", 2)
+		date, err := dslDate(parts[0], "", loc)
+		if err != nil {
+			return time.Time{}, err
+		}
+		t, expr = date.In(loc), parts[1]
+	default:
+		return dslDate(s, "", loc)
+	}
+
+	for expr != "" {
+		m := dateMathRegexp.FindStringSubmatch(expr)
+		if m == nil {
+			return time.Time{}, fmt.Errorf("invalid date math [%s] at [%s]", s, expr)
+		}
+		expr = expr[len(m[0]):]
+
+		if m[1] == "/" {
+			t = roundDate(t, m[2])
+			continue
+		}
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date math [%s]: %v", s, err)
+		}
+		t = addDate(t, n, m[2])
+	}
+
+	return t, nil
+}
+
+// addDate adds n units to t, calendar units in the location of t.
+func addDate(t time.Time, n int, unit string) time.Time {
+	switch unit {
+	case "y":
+		return t.AddDate(n, 0, 0)
+	case "M":
+		return t.AddDate(0, n, 0)
+	case "w":
+		return t.AddDate(0, 0, 7*n)
+	case "d":
+		return t.AddDate(0, 0, n)
+	case "h", "H":
+		return t.Add(time.Duration(n) * time.Hour)
+	case "m":
+		return t.Add(time.Duration(n) * time.Minute)
+	}
+	return t.Add(time.Duration(n) * time.Second)
+}
+
+// roundDate rounds t down to the start of its unit, in the location of t. Weeks start on Monday.
+func roundDate(t time.Time, unit string) time.Time {
+	if unit == "s" {
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, t.Location())
+	}
+	units := map[string]string{"y": "year", "M": "month", "w": "week", "d": "day", "h": "hour", "H": "hour", "m": "minute"}
+	return dateInterval{unit: units[unit]}.floor(t)
+}
+
+// parseISODuration parses an ISO 8601 duration like P1DT12H, and returns the function that subtracts it from a time.
+func parseISODuration(s string) (func(time.Time) time.Time, error) {
+	m := isoDurationRegexp.FindStringSubmatch(s)
+	if m == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return nil, fmt.Errorf("invalid ISO 8601 duration [%s]", s)
+	}
+
+	n := make([]int, 6)
+	for i := range n {
+		n[i], _ = strconv.Atoi(m[i+1])
+	}
+	seconds, _ := strconv.ParseFloat(m[7], 64)
+	d := time.Duration(n[4])*time.Hour + time.Duration(n[5])*time.Minute + time.Duration(seconds*float64(time.Second))
+
+	return func(t time.Time) time.Time {
+		return t.AddDate(-n[0], -n[1], -7*n[2]-n[3]).Add(-d)
+	}, nil
+}
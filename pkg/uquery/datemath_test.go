@@ -0,0 +1,78 @@
+package uquery
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDateMath(t *testing.T) {
+	// a Wednesday
+	now := time.Date(2024, time.March, 13, 15, 47, 23, 500000000, time.UTC)
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		s       string
+		loc     *time.Location
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "now", s: "now", want: now},
+		{name: "minus minutes", s: "now-15m", want: now.Add(-15 * time.Minute)},
+		{name: "plus hours", s: "now+2h", want: now.Add(2 * time.Hour)},
+		{name: "hours as H", s: "now-1H", want: now.Add(-time.Hour)},
+		{name: "seconds", s: "now-30s", want: now.Add(-30 * time.Second)},
+		{name: "days", s: "now-1d", want: time.Date(2024, time.March, 12, 15, 47, 23, 500000000, time.UTC)},
+		{name: "weeks", s: "now-2w", want: time.Date(2024, time.February, 28, 15, 47, 23, 500000000, time.UTC)},
+		{name: "months", s: "now-1M", want: time.Date(2024, time.February, 13, 15, 47, 23, 500000000, time.UTC)},
+		{name: "years", s: "now+1y", want: time.Date(2025, time.March, 13, 15, 47, 23, 500000000, time.UTC)},
+		{name: "round to the second", s: "now/s", want: time.Date(2024, time.March, 13, 15, 47, 23, 0, time.UTC)},
+		{name: "round to the minute", s: "now/m", want: time.Date(2024, time.March, 13, 15, 47, 0, 0, time.UTC)},
+		{name: "round to the hour", s: "now/h", want: time.Date(2024, time.March, 13, 15, 0, 0, 0, time.UTC)},
+		{name: "round to the day", s: "now/d", want: time.Date(2024, time.March, 13, 0, 0, 0, 0, time.UTC)},
+		{name: "round to the week", s: "now/w", want: time.Date(2024, time.March, 11, 0, 0, 0, 0, time.UTC)},
+		{name: "round to the month", s: "now/M", want: time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "round to the year", s: "now/y", want: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "start of yesterday", s: "now-1d/d", want: time.Date(2024, time.March, 12, 0, 0, 0, 0, time.UTC)},
+		{name: "several operations", s: "now-1M/M+1w", want: time.Date(2024, time.February, 8, 0, 0, 0, 0, time.UTC)},
+		{name: "round in the location", s: "now/d", loc: paris, want: time.Date(2024, time.March, 13, 0, 0, 0, 0, paris)},
+		{name: "anchor date", s: "2024-01-15||+1M", want: time.Date(2024, time.February, 15, 0, 0, 0, 0, time.UTC)},
+		{name: "anchor date in the location", s: "2024-01-15T10:00:00||/d", loc: paris, want: time.Date(2024, time.January, 15, 0, 0, 0, 0, paris)},
+		{name: "anchor RFC 3339 date", s: "2024-01-15T10:00:00Z||-1h", want: time.Date(2024, time.January, 15, 9, 0, 0, 0, time.UTC)},
+		{name: "date", s: "2024-01-15", want: time.Date(2024, time.January, 15, 0, 0, 0, 0, time.UTC)},
+		{name: "date in the location", s: "2024-01-15 08:30:00", loc: paris, want: time.Date(2024, time.January, 15, 8, 30, 0, 0, paris)},
+		{name: "ISO duration", s: "PT15M", want: now.Add(-15 * time.Minute)},
+		{name: "ISO duration of days and hours", s: "P1DT12H", want: time.Date(2024, time.March, 12, 3, 47, 23, 500000000, time.UTC)},
+		{name: "ISO duration of months", s: "P1M", want: time.Date(2024, time.February, 13, 15, 47, 23, 500000000, time.UTC)},
+		{name: "ISO duration of years and weeks", s: "P1Y2W", want: time.Date(2023, time.February, 27, 15, 47, 23, 500000000, time.UTC)},
+		{name: "ISO duration of fractional seconds", s: "PT1.5S", want: time.Date(2024, time.March, 13, 15, 47, 22, 0, time.UTC)},
+		{name: "invalid unit", s: "now-1x", wantErr: true},
+		{name: "missing amount", s: "now-d", wantErr: true},
+		{name: "trailing text", s: "now-1d foo", wantErr: true},
+		{name: "invalid anchor date", s: "yesterday||-1d", wantErr: true},
+		{name: "invalid date", s: "15/01/2024", wantErr: true},
+		{name: "empty ISO duration", s: "P", wantErr: true},
+		{name: "ISO duration ending with T", s: "P1DT", wantErr: true},
+		{name: "ISO duration in the wrong order", s: "PT1S1M", wantErr: true},
+		{name: "ISO duration without units", s: "P15", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc := tt.loc
+			if loc == nil {
+				loc = time.UTC
+			}
+			got, err := parseDateMath(tt.s, now, loc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDateMath(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseDateMath(%q) = %v, want %v", tt.s, got, tt.want)
+			}
+		})
+	}
+}
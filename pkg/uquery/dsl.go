@@ -0,0 +1,577 @@
+package uquery
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blugelabs/bluge"
+	"github.com/prabhatsharma/zinc/pkg/analyzer"
+	v1 "github.com/prabhatsharma/zinc/pkg/meta/v1"
+)
+
+// Field types of the index mapping that change how a query value is matched, see core.FieldTypeNumeric and others.
+const (
+	fieldTypeNumeric = "numeric"
+	fieldTypeDate    = "date"
+	fieldTypeTime    = "time"
+	fieldTypeBool    = "bool"
+)
+
+// dslDateLayouts are the layouts of date strings in range and term queries on date fields.
+var dslDateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// DSLSearch builds the search request of an Elasticsearch query DSL search.
+//
+// Supported queries are match_all, match_none, match, match_phrase, multi_match, query_string, simple_query_string,
+// term, terms, ids, range, prefix, wildcard, exists and bool with must, should, must_not, filter and minimum_should_match.
+func DSLSearch(q v1.DSLQuery) (bluge.SearchRequest, error) {
+	query, err := dslQuery{q}.compile(q.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	size := 10
+	if q.Size != nil {
+		size = *q.Size
+	}
+	if size < 0 || q.From < 0 {
+		return nil, fmt.Errorf("[from] and [size] cannot be negative")
+	}
+
+	sortFields, err := dslSort(q.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	return bluge.NewTopNSearch(size, query).
+		SetFrom(q.From).
+		SortBy(sortFields).
+		WithStandardAggregations(), nil
+}
+
+// dslQuery compiles the query DSL objects of a search request, looking up the mapping of the fields it names.
+type dslQuery struct {
+	v1.DSLQuery
+}
+
+// compile builds the bluge query of a query DSL object. The empty object matches all documents.
+func (q dslQuery) compile(obj map[string]interface{}) (bluge.Query, error) {
+	if len(obj) == 0 {
+		return bluge.NewMatchAllQuery(), nil
+	}
+	if len(obj) != 1 {
+		return nil, fmt.Errorf("a query must have a single type, found %d: %v", len(obj), mapKeys(obj))
+	}
+
+	for typ, body := range obj {
+		switch typ {
+		case "match_all":
+			return bluge.NewMatchAllQuery(), nil
+		case "match_none":
+			return bluge.NewMatchNoneQuery(), nil
+		case "bool":
+			return q.boolQuery(body)
+		case "match", "match_phrase":
+			return q.matchQuery(typ, body)
+		case "multi_match":
+			return q.multiMatchQuery(body)
+		case "query_string", "simple_query_string":
+			params, ok := body.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("[%s] must be an object", typ)
+			}
+			analyzerName, _ := params["analyzer"].(string)
+			return parseQueryString(dslString(params["query"]), analyzerName, q.FieldAnalyzers)
+		case "term":
+			field, params, err := dslField(typ, body, "value")
+			if err != nil {
+				return nil, err
+			}
+			return q.termQuery(field, params["value"])
+		case "terms":
+			return q.termsQuery(body)
+		case "ids":
+			params, ok := body.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("[ids] must be an object")
+			}
+			values, _ := params["values"].([]interface{})
+			query := bluge.NewBooleanQuery()
+			for _, id := range values {
+				query.AddShould(bluge.NewTermQuery(dslString(id)).SetField("_id"))
+			}
+			return query, nil
+		case "range":
+			return q.rangeQuery(body)
+		case "prefix":
+			field, params, err := dslField(typ, body, "value")
+			if err != nil {
+				return nil, err
+			}
+			return bluge.NewPrefixQuery(dslString(params["value"])).SetField(field), nil
+		case "wildcard":
+			field, params, err := dslField(typ, body, "value")
+			if err != nil {
+				return nil, err
+			}
+			return bluge.NewWildcardQuery(dslString(params["value"])).SetField(field), nil
+		case "exists":
+			params, ok := body.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("[exists] must be an object")
+			}
+			return q.existsQuery(dslString(params["field"])), nil
+		default:
+			return nil, fmt.Errorf("unsupported query type [%s]", typ)
+		}
+	}
+
+	return nil, nil // not reached
+}
+
+// fieldType returns the mapped type of the field. The @timestamp field of every document is a date.
+func (q dslQuery) fieldType(field string) string {
+	if field == "@timestamp" {
+		return fieldTypeDate
+	}
+	return q.FieldTypes[field]
+}
+
+func (q dslQuery) boolQuery(body interface{}) (bluge.Query, error) {
+	params, ok := body.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("[bool] must be an object")
+	}
+
+	clauses := make(map[string][]bluge.Query)
+	for _, occur := range []string{"must", "filter", "should", "must_not"} {
+		var objs []interface{}
+		switch v := params[occur].(type) {
+		case nil:
+			continue
+		case []interface{}:
+			objs = v
+		default:
+			objs = []interface{}{v}
+		}
+
+		for _, obj := range objs {
+			m, ok := obj.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("[bool] clause [%s] must be a query object or a list of them", occur)
+			}
+			clause, err := q.compile(m)
+			if err != nil {
+				return nil, err
+			}
+			clauses[occur] = append(clauses[occur], clause)
+		}
+	}
+
+	return combineBool(clauses, params["minimum_should_match"])
+}
+
+// minimumShouldMatch returns the number of should clauses to match from a count, a negative count or a percentage.
+func minimumShouldMatch(value interface{}, shoulds int) (int, error) {
+	s := strings.TrimSpace(dslString(value))
+	percent := strings.HasSuffix(s, "%")
+	n, err := strconv.Atoi(strings.TrimSuffix(s, "%"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid minimum_should_match [%v]", value)
+	}
+	if percent {
+		n = shoulds * n / 100
+	}
+	if n < 0 {
+		n += shoulds
+	}
+	if n < 0 {
+		n = 0
+	}
+	if n > shoulds {
+		n = shoulds
+	}
+	return n, nil
+}
+
+func (q dslQuery) matchQuery(typ string, body interface{}) (bluge.Query, error) {
+	field, params, err := dslField(typ, body, "query")
+	if err != nil {
+		return nil, err
+	}
+
+	analyzerName, _ := params["analyzer"].(string)
+	if analyzerName == "" {
+		analyzerName = q.FieldAnalyzers[field]
+	}
+	a, err := analyzer.Get(analyzerName)
+	if err != nil {
+		return nil, err
+	}
+
+	text := dslString(params["query"])
+	if typ == "match_phrase" {
+		return bluge.NewMatchPhraseQuery(text).SetField(field).SetAnalyzer(a), nil
+	}
+
+	query := bluge.NewMatchQuery(text).SetField(field).SetAnalyzer(a)
+	if strings.EqualFold(dslString(params["operator"]), "and") {
+		query.SetOperator(bluge.MatchQueryOperatorAnd)
+	}
+	if fuzziness, ok := params["fuzziness"]; ok {
+		n, err := strconv.Atoi(dslString(fuzziness))
+		if err != nil {
+			return nil, fmt.Errorf("invalid fuzziness [%v], expected an edit distance of 0, 1 or 2", fuzziness)
+		}
+		query.SetFuzziness(n)
+	}
+	return query, nil
+}
+
+// multiMatchQuery matches the query in any of the fields, which may have a ^boost suffix.
+func (q dslQuery) multiMatchQuery(body interface{}) (bluge.Query, error) {
+	params, ok := body.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("[multi_match] must be an object")
+	}
+
+	fields, _ := params["fields"].([]interface{})
+	if len(fields) == 0 {
+		fields = []interface{}{"_all"}
+	}
+
+	typ := "match"
+	if dslString(params["type"]) == "phrase" {
+		typ = "match_phrase"
+	}
+
+	query := bluge.NewBooleanQuery()
+	for _, f := range fields {
+		field := strings.SplitN(dslString(f), "^", 2)[0]
+		match := map[string]interface{}{"query": params["query"], "operator": params["operator"], "analyzer": params["analyzer"]}
+		if match["analyzer"] == nil {
+			delete(match, "analyzer")
+		}
+		fieldQuery, err := q.matchQuery(typ, map[string]interface{}{field: match})
+		if err != nil {
+			return nil, err
+		}
+		query.AddShould(fieldQuery)
+	}
+	return query, nil
+}
+
+// termQuery matches the exact value, compared as a number, a date or a boolean according to the type of the field.
+func (q dslQuery) termQuery(field string, value interface{}) (bluge.Query, error) {
+	switch q.fieldType(field) {
+	case fieldTypeNumeric:
+		n, err := dslNumber(value)
+		if err != nil {
+			return nil, fmt.Errorf("[term] field [%s]: %v", field, err)
+		}
+		return bluge.NewNumericRangeInclusiveQuery(n, n, true, true).SetField(field), nil
+	case fieldTypeDate, fieldTypeTime:
+		t, err := dslDate(value, "", nil)
+		if err != nil {
+			return nil, fmt.Errorf("[term] field [%s]: %v", field, err)
+		}
+		return bluge.NewDateRangeInclusiveQuery(t, t, true, true).SetField(field), nil
+	}
+
+	return bluge.NewTermQuery(dslString(value)).SetField(field), nil
+}
+
+func (q dslQuery) termsQuery(body interface{}) (bluge.Query, error) {
+	params, ok := body.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("[terms] must be an object")
+	}
+
+	query := bluge.NewBooleanQuery()
+	fields := 0
+	for field, v := range params {
+		if field == "boost" {
+			continue
+		}
+		values, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("[terms] field [%s] must be a list of values", field)
+		}
+		if fields++; fields > 1 {
+			return nil, fmt.Errorf("[terms] must have a single field")
+		}
+
+		for _, value := range values {
+			term, err := q.termQuery(field, value)
+			if err != nil {
+				return nil, err
+			}
+			query.AddShould(term)
+		}
+	}
+
+	if len(query.Shoulds()) == 0 {
+		return bluge.NewMatchNoneQuery(), nil
+	}
+	return query, nil
+}
+
+// rangeQuery matches numbers, dates or terms between gt or gte and lt or lte, according to the type of the field.
+func (q dslQuery) rangeQuery(body interface{}) (bluge.Query, error) {
+	field, params, err := dslField("range", body, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var lower, upper interface{}
+	lowerInclusive, upperInclusive := true, true
+	for name, v := range params {
+		switch name {
+		case "gte", "from":
+			lower = v
+		case "gt":
+			lower, lowerInclusive = v, false
+		case "lte", "to":
+			upper = v
+		case "lt":
+			upper, upperInclusive = v, false
+		}
+	}
+	if lower == nil && upper == nil {
+		return nil, fmt.Errorf("[range] field [%s] must have a lower or an upper bound", field)
+	}
+
+	typ := q.fieldType(field)
+	if typ == "" && (isNumber(lower) || isNumber(upper)) {
+		typ = fieldTypeNumeric
+	}
+
+	switch typ {
+	case fieldTypeDate, fieldTypeTime:
+		var loc *time.Location
+		if tz := dslString(params["time_zone"]); tz != "" {
+			if loc, err = dslLocation(tz); err != nil {
+				return nil, err
+			}
+		}
+		format := dslString(params["format"])
+		var start, end time.Time
+		if lower != nil {
+			if start, err = dslDate(lower, format, loc); err != nil {
+				return nil, fmt.Errorf("[range] field [%s]: %v", field, err)
+			}
+		}
+		if upper != nil {
+			if end, err = dslDate(upper, format, loc); err != nil {
+				return nil, fmt.Errorf("[range] field [%s]: %v", field, err)
+			}
+		}
+		return bluge.NewDateRangeInclusiveQuery(start, end, lowerInclusive, upperInclusive).SetField(field), nil
+	case fieldTypeNumeric:
+		min, max := bluge.MinNumeric, bluge.MaxNumeric
+		if lower != nil {
+			if min, err = dslNumber(lower); err != nil {
+				return nil, fmt.Errorf("[range] field [%s]: %v", field, err)
+			}
+		}
+		if upper != nil {
+			if max, err = dslNumber(upper); err != nil {
+				return nil, fmt.Errorf("[range] field [%s]: %v", field, err)
+			}
+		}
+		return bluge.NewNumericRangeInclusiveQuery(min, max, lowerInclusive, upperInclusive).SetField(field), nil
+	}
+
+	var min, max string
+	if lower != nil {
+		min = dslString(lower)
+	}
+	if upper != nil {
+		max = dslString(upper)
+	}
+	return bluge.NewTermRangeInclusiveQuery(min, max, lowerInclusive, upperInclusive).SetField(field), nil
+}
+
+// existsQuery matches the documents that have a value for the field.
+func (q dslQuery) existsQuery(field string) bluge.Query {
+	switch q.fieldType(field) {
+	case "":
+		return bluge.NewMatchNoneQuery()
+	case fieldTypeNumeric:
+		return bluge.NewNumericRangeInclusiveQuery(-math.MaxFloat64, math.MaxFloat64, true, true).SetField(field)
+	case fieldTypeDate, fieldTypeTime:
+		return bluge.NewDateRangeQuery(time.Time{}, time.Time{}).SetField(field) // unbounded on both ends
+	case fieldTypeBool:
+		return bluge.NewBooleanQuery().
+			AddShould(bluge.NewTermQuery("true").SetField(field)).
+			AddShould(bluge.NewTermQuery("false").SetField(field))
+	}
+
+	return bluge.NewWildcardQuery("*").SetField(field)
+}
+
+// dslSort converts the sort of the query DSL to bluge sort fields, e.g. [{"@timestamp": "desc"}, "_score"].
+func dslSort(sort interface{}) ([]string, error) {
+	var specs []interface{}
+	switch v := sort.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		specs = v
+	default:
+		specs = []interface{}{v}
+	}
+
+	var fields []string
+	for _, spec := range specs {
+		var field, order string
+		switch v := spec.(type) {
+		case string:
+			field = v
+		case map[string]interface{}:
+			if len(v) != 1 {
+				return nil, fmt.Errorf("a sort object must have a single field, found %v", mapKeys(v))
+			}
+			for f, o := range v {
+				field = f
+				if params, ok := o.(map[string]interface{}); ok {
+					order = dslString(params["order"])
+				} else {
+					order = dslString(o)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("invalid sort [%v]", spec)
+		}
+
+		if order == "" {
+			order = "asc"
+			if field == "_score" {
+				order = "desc" // highest scores first
+			}
+		}
+		switch order {
+		case "asc":
+			fields = append(fields, field)
+		case "desc":
+			fields = append(fields, "-"+field)
+		default:
+			return nil, fmt.Errorf("invalid sort order [%s] of field [%s], expected asc or desc", order, field)
+		}
+	}
+
+	return fields, nil
+}
+
+// dslField returns the field of a leaf query like {"status": "error"} or {"status": {"value": "error"}}, and its parameters.
+// A value given without parameters is returned as the parameter named by key.
+func dslField(typ string, body interface{}, key string) (string, map[string]interface{}, error) {
+	obj, ok := body.(map[string]interface{})
+	if !ok || len(obj) != 1 {
+		return "", nil, fmt.Errorf("[%s] must be an object with a single field", typ)
+	}
+
+	for field, v := range obj {
+		if params, ok := v.(map[string]interface{}); ok {
+			return field, params, nil
+		}
+		if key == "" {
+			return "", nil, fmt.Errorf("[%s] field [%s] must be an object", typ, field)
+		}
+		return field, map[string]interface{}{key: v}, nil
+	}
+
+	return "", nil, nil // not reached
+}
+
+// dslString returns the value as a string, formatting numbers without exponent.
+func dslString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	return fmt.Sprint(value)
+}
+
+func dslNumber(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case string:
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n, nil
+		}
+	}
+
+	return 0, fmt.Errorf("[%v] is not a number", value)
+}
+
+func isNumber(value interface{}) bool {
+	_, ok := value.(float64)
+	return ok
+}
+
+// dslDate converts a date string, date math like now-1d/d or an epoch number, in milliseconds unless the format
+// is epoch_second. Date strings without a time zone and date math are taken in loc, or UTC if loc is nil.
+func dslDate(value interface{}, format string, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	n, err := dslNumber(value)
+	if err == nil && (isNumber(value) || strings.Contains(format, "epoch")) {
+		if strings.Contains(format, "epoch_second") {
+			return time.Unix(0, int64(n*float64(time.Second))), nil
+		}
+		return time.Unix(0, int64(n*float64(time.Millisecond))), nil
+	}
+
+	s := dslString(value)
+	if strings.HasPrefix(s, "now") || strings.Contains(s, "||") {
+		return parseDateMath(s, time.Now(), loc)
+	}
+	for _, layout := range dslDateLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("[%v] is not a date, expected RFC 3339 or epoch milliseconds", value)
+}
+
+// dslLocation returns the location of a time zone name like Europe/Paris or an offset like +01:00.
+func dslLocation(tz string) (*time.Location, error) {
+	if t, err := time.Parse("-07:00", tz); err == nil {
+		_, offset := t.Zone()
+		return time.FixedZone(tz, offset), nil
+	}
+
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time_zone [%s]", tz)
+	}
+	return loc, nil
+}
+
+func mapKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,128 @@
+package uquery
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+
+	v1 "github.com/prabhatsharma/zinc/pkg/meta/v1"
+)
+
+func TestDSLCompile(t *testing.T) {
+	fieldTypes := map[string]string{"status": "numeric", "created": "date", "active": "bool", "name": "keyword"}
+	maxFloat := strconv.FormatFloat(math.MaxFloat64, 'f', -1, 64)
+
+	tests := []struct {
+		name    string
+		query   string
+		want    string
+		wantErr string
+	}{
+		{name: "empty", query: `{}`, want: "*"},
+		{name: "match_all", query: `{"match_all": {}}`, want: "*"},
+		{name: "match_none", query: `{"match_none": {}}`, want: "MatchNoneQuery"},
+		{name: "match", query: `{"match": {"message": "error"}}`, want: "message:error"},
+		{name: "match with parameters", query: `{"match": {"message": {"query": "disk full", "operator": "and", "fuzziness": 1}}}`, want: "message:disk full"},
+		{name: "match_phrase", query: `{"match_phrase": {"message": "disk full"}}`, want: `message:"disk full"`},
+		{name: "multi_match", query: `{"multi_match": {"query": "x", "fields": ["a^2", "b"]}}`, want: "(a:x b:x)"},
+		{name: "multi_match on all fields", query: `{"multi_match": {"query": "x", "type": "phrase"}}`, want: `(_all:"x")`},
+		{name: "term", query: `{"term": {"name": "bob"}}`, want: "name:bob"},
+		{name: "term with value", query: `{"term": {"name": {"value": "bob"}}}`, want: "name:bob"},
+		{name: "term on a number", query: `{"term": {"status": 500}}`, want: "status:[500 TO 500]"},
+		{name: "term on a date", query: `{"term": {"created": "2024-01-15"}}`, want: "DateRangeQuery"},
+		{name: "terms", query: `{"terms": {"status": [404, "500"]}}`, want: "(status:[404 TO 404] status:[500 TO 500])"},
+		{name: "empty terms", query: `{"terms": {"name": []}}`, want: "MatchNoneQuery"},
+		{name: "ids", query: `{"ids": {"values": ["1", 2]}}`, want: "(_id:1 _id:2)"},
+		{name: "numeric range", query: `{"range": {"status": {"gte": 500, "lt": 600}}}`, want: "status:[500 TO 600}"},
+		{name: "range on an unmapped field with numbers", query: `{"range": {"size": {"gt": 10}}}`, want: "size:{10 TO *]"},
+		{name: "date range", query: `{"range": {"@timestamp": {"gte": "now-1d/d", "time_zone": "+01:00"}}}`, want: "DateRangeQuery"},
+		{name: "term range", query: `{"range": {"name": {"gte": "a", "lte": "m"}}}`, want: "TermRangeQuery"},
+		{name: "prefix", query: `{"prefix": {"name": "bo"}}`, want: "name:bo*"},
+		{name: "wildcard", query: `{"wildcard": {"name": {"value": "b?b*"}}}`, want: "name:b?b*"},
+		{name: "exists on a keyword", query: `{"exists": {"field": "name"}}`, want: "name:*"},
+		{name: "exists on a number", query: `{"exists": {"field": "status"}}`, want: "status:[-" + maxFloat + " TO " + maxFloat + "]"},
+		{name: "exists on a bool", query: `{"exists": {"field": "active"}}`, want: "(active:true active:false)"},
+		{name: "exists on an unmapped field", query: `{"exists": {"field": "missing"}}`, want: "MatchNoneQuery"},
+		{
+			name:  "bool",
+			query: `{"bool": {"must": {"term": {"name": "bob"}}, "filter": [{"term": {"status": 500}}], "should": [{"prefix": {"name": "a"}}], "must_not": [{"match_phrase": {"message": "ok"}}]}}`,
+			want:  `(+name:bob +(+status:[500 TO 500]) name:a* -message:"ok")`,
+		},
+		{name: "empty bool", query: `{"bool": {}}`, want: "*"},
+		{name: "nested bool", query: `{"bool": {"should": [{"bool": {"must_not": {"term": {"name": "x"}}}}]}}`, want: "((-name:x))"},
+		{name: "two query types", query: `{"term": {"a": "x"}, "prefix": {"b": "y"}}`, wantErr: "a query must have a single type, found 2: [prefix term]"},
+		{name: "unsupported query type", query: `{"fuzzy": {"a": "x"}}`, wantErr: "unsupported query type [fuzzy]"},
+		{name: "term on two fields", query: `{"term": {"a": "x", "b": "y"}}`, wantErr: "[term] must be an object with a single field"},
+		{name: "term with an invalid number", query: `{"term": {"status": "high"}}`, wantErr: "[term] field [status]: [high] is not a number"},
+		{name: "terms on two fields", query: `{"terms": {"a": ["x"], "b": ["y"]}}`, wantErr: "[terms] must have a single field"},
+		{name: "range without bounds", query: `{"range": {"status": {"boost": 2}}}`, wantErr: "[range] field [status] must have a lower or an upper bound"},
+		{name: "range with an invalid date", query: `{"range": {"created": {"gte": "yesterday"}}}`, wantErr: "[range] field [created]: [yesterday] is not a date, expected RFC 3339 or epoch milliseconds"},
+		{name: "match with an invalid fuzziness", query: `{"match": {"a": {"query": "x", "fuzziness": "AUTO"}}}`, wantErr: "invalid fuzziness [AUTO], expected an edit distance of 0, 1 or 2"},
+		{name: "bool with an invalid clause", query: `{"bool": {"must": ["x"]}}`, wantErr: "[bool] clause [must] must be a query object or a list of them"},
+		{name: "bool with an invalid minimum_should_match", query: `{"bool": {"should": [{"match_all": {}}], "minimum_should_match": "some"}}`, wantErr: "invalid minimum_should_match [some]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var obj map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.query), &obj); err != nil {
+				t.Fatal(err)
+			}
+			got, err := dslQuery{v1.DSLQuery{FieldTypes: fieldTypes}}.compile(obj)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("compile() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("compile() error = %v", err)
+			}
+			if s := describeQuery(got); s != tt.want {
+				t.Errorf("compile() = %s, want %s", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestDSLSort(t *testing.T) {
+	tests := []struct {
+		name    string
+		sort    string
+		want    []string
+		wantErr string
+	}{
+		{name: "none", sort: `null`},
+		{name: "field", sort: `"name"`, want: []string{"name"}},
+		{name: "score", sort: `"_score"`, want: []string{"-_score"}},
+		{name: "order", sort: `{"@timestamp": "desc"}`, want: []string{"-@timestamp"}},
+		{name: "order object", sort: `[{"@timestamp": {"order": "desc"}}, {"name": {"order": "asc"}}, "_score"]`, want: []string{"-@timestamp", "name", "-_score"}},
+		{name: "invalid order", sort: `{"name": "up"}`, wantErr: "invalid sort order [up] of field [name], expected asc or desc"},
+		{name: "two fields in an object", sort: `{"a": "asc", "b": "asc"}`, wantErr: "a sort object must have a single field, found [a b]"},
+		{name: "invalid sort", sort: `[1]`, wantErr: "invalid sort [1]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sort interface{}
+			if err := json.Unmarshal([]byte(tt.sort), &sort); err != nil {
+				t.Fatal(err)
+			}
+			got, err := dslSort(sort)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("dslSort() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("dslSort() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dslSort() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
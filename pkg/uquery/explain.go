@@ -0,0 +1,207 @@
+package uquery
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+)
+
+// ExplainQuery returns the query of the query string that the explain API explains.
+func ExplainQuery(term string, fieldAnalyzers map[string]string) (bluge.Query, error) {
+	return parseQueryString(term, "", fieldAnalyzers)
+}
+
+// ExplainRequest returns the search request that explains the score of the document with the id for the query.
+// The id only selects the document, it is not part of the query, so the explanation is the one of the query alone.
+func ExplainRequest(docID string, query bluge.Query) bluge.SearchRequest {
+	return bluge.NewTopNSearch(1, documentQuery{docID: docID, query: query}).ExplainScores()
+}
+
+// ExplainMiss explains why a document does not match the query: it tells which clauses of a boolean query, and of
+// the boolean queries nested in it, the document matches and misses. explain returns the match of a query for the
+// document, nil if the document does not match it.
+func ExplainMiss(query bluge.Query, explain func(bluge.Query) (*search.DocumentMatch, error)) (*search.Explanation, error) {
+	b, ok := query.(*bluge.BooleanQuery)
+	if !ok {
+		return search.NewExplanation(0, "no match on "+describeQuery(query)), nil
+	}
+
+	var clauses []*search.Explanation
+	shoulds := 0
+	for _, occur := range []struct {
+		name    string
+		clauses []bluge.Query
+	}{
+		{"required", b.Musts()},
+		{"optional", b.Shoulds()},
+		{"prohibited", b.MustNots()},
+	} {
+		for _, clause := range occur.clauses {
+			description := occur.name + " clause " + describeQuery(clause)
+			match, err := explain(clause)
+			if err != nil {
+				return nil, err
+			}
+			if match != nil {
+				if occur.name == "optional" {
+					shoulds++
+				}
+				clauses = append(clauses, search.NewExplanation(match.Score, "match on "+description, match.Explanation))
+				continue
+			}
+
+			miss, err := ExplainMiss(clause, explain)
+			if err != nil {
+				return nil, err
+			}
+			clauses = append(clauses, search.NewExplanation(0, "no match on "+description, miss.Children...))
+		}
+	}
+
+	if len(b.Shoulds()) > 0 {
+		min := b.MinShould()
+		if min == 0 && len(b.Musts()) == 0 {
+			min = 1
+		}
+		if shoulds < min {
+			clauses = append(clauses, search.NewExplanation(0,
+				fmt.Sprintf("%d optional clauses match, at least %d must match", shoulds, min)))
+		}
+	}
+
+	return search.NewExplanation(0, "no match on "+describeQuery(query)+", the clauses of the query:", clauses...), nil
+}
+
+// describeQuery returns the query in the query string syntax, as far as the bluge query tells it.
+func describeQuery(query bluge.Query) string {
+	field := func(f string) string {
+		if f == "" {
+			return ""
+		}
+		return f + ":"
+	}
+
+	switch q := query.(type) {
+	case *bluge.BooleanQuery:
+		var clauses []string
+		for _, c := range q.Musts() {
+			clauses = append(clauses, "+"+describeQuery(c))
+		}
+		for _, c := range q.Shoulds() {
+			clauses = append(clauses, describeQuery(c))
+		}
+		for _, c := range q.MustNots() {
+			clauses = append(clauses, "-"+describeQuery(c))
+		}
+		return "(" + strings.Join(clauses, " ") + ")"
+	case *bluge.TermQuery:
+		return field(q.Field()) + q.Term()
+	case *bluge.MatchQuery:
+		return field(q.Field()) + q.Match()
+	case *bluge.MatchPhraseQuery:
+		return field(q.Field()) + strconv.Quote(q.Phrase())
+	case *bluge.PrefixQuery:
+		return field(q.Field()) + q.Prefix() + "*"
+	case *bluge.WildcardQuery:
+		return field(q.Field()) + q.Wildcard()
+	case *bluge.RegexpQuery:
+		return field(q.Field()) + "/" + q.Regexp() + "/"
+	case *bluge.FuzzyQuery:
+		return field(q.Field()) + q.Term() + "~" + strconv.Itoa(q.Fuzziness())
+	case *bluge.NumericRangeQuery:
+		min, minInclusive := q.Min()
+		max, maxInclusive := q.Max()
+		bound := func(n float64) string {
+			if math.IsInf(n, 0) {
+				return "*"
+			}
+			return strconv.FormatFloat(n, 'f', -1, 64)
+		}
+		open, close := "{", "}"
+		if minInclusive {
+			open = "["
+		}
+		if maxInclusive {
+			close = "]"
+		}
+		return field(q.Field()) + open + bound(min) + " TO " + bound(max) + close
+	case *bluge.MatchAllQuery:
+		return "*"
+	}
+
+	return strings.TrimPrefix(fmt.Sprintf("%T", query), "*bluge.")
+}
+
+// documentQuery matches the document with the id if it matches the query, with the score of the query.
+type documentQuery struct {
+	docID string
+	query bluge.Query
+}
+
+func (q documentQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	ids, err := bluge.NewTermQuery(q.docID).SetField("_id").Searcher(i, options)
+	if err != nil {
+		return nil, err
+	}
+	s, err := q.query.Searcher(i, options)
+	if err != nil {
+		_ = ids.Close()
+		return nil, err
+	}
+	return &documentSearcher{Searcher: s, ids: ids}, nil
+}
+
+// documentSearcher is the searcher of a documentQuery. It advances the searcher of the query to the document of the
+// id, so the matches, scores and explanations are the ones of the query.
+type documentSearcher struct {
+	search.Searcher
+	ids search.Searcher
+}
+
+func (s *documentSearcher) Next(ctx *search.Context) (*search.DocumentMatch, error) {
+	id, err := s.ids.Next(ctx)
+	if err != nil || id == nil {
+		return nil, err
+	}
+	return s.advanceTo(ctx, id)
+}
+
+func (s *documentSearcher) Advance(ctx *search.Context, number uint64) (*search.DocumentMatch, error) {
+	id, err := s.ids.Advance(ctx, number)
+	if err != nil || id == nil {
+		return nil, err
+	}
+	return s.advanceTo(ctx, id)
+}
+
+// advanceTo returns the match of the query for the document of the id, nil if the query does not match it.
+func (s *documentSearcher) advanceTo(ctx *search.Context, id *search.DocumentMatch) (*search.DocumentMatch, error) {
+	number := id.Number
+	ctx.DocumentMatchPool.Put(id)
+
+	match, err := s.Searcher.Advance(ctx, number)
+	if err != nil || match == nil {
+		return nil, err
+	}
+	if match.Number != number {
+		ctx.DocumentMatchPool.Put(match)
+		return nil, nil
+	}
+	return match, nil
+}
+
+func (s *documentSearcher) Close() error {
+	err := s.Searcher.Close()
+	if idsErr := s.ids.Close(); err == nil {
+		err = idsErr
+	}
+	return err
+}
+
+func (s *documentSearcher) DocumentMatchPoolSize() int {
+	return s.Searcher.DocumentMatchPoolSize() + s.ids.DocumentMatchPoolSize()
+}
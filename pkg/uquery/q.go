@@ -4,15 +4,14 @@ import (
 	"fmt"
 
 	"github.com/blugelabs/bluge"
-	"github.com/blugelabs/bluge/analysis/analyzer"
+	"github.com/blugelabs/bluge/analysis"
 	qs "github.com/blugelabs/query_string"
+	"github.com/prabhatsharma/zinc/pkg/analyzer"
 	v1 "github.com/prabhatsharma/zinc/pkg/meta/v1"
 )
 
 func WildcardQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
 	// requestedIndex := startup.INDEX_WRITER_LIST[indexName]
-	dateQuery := bluge.NewDateRangeQuery(iQuery.Query.StartTime, iQuery.Query.EndTime).SetField("@timestamp")
-
 	var field string
 	if iQuery.Query.Field != "" {
 		field = iQuery.Query.Field
@@ -21,7 +20,10 @@ func WildcardQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
 	}
 
 	wildcardQuery := bluge.NewWildcardQuery(iQuery.Query.Term).SetField(field)
-	query := bluge.NewBooleanQuery().AddMust(dateQuery).AddMust(wildcardQuery)
+	query, err := withTimeRange(iQuery, wildcardQuery)
+	if err != nil {
+		return nil, err
+	}
 
 	searchRequest := buildRequest(iQuery, query)
 
@@ -29,8 +31,6 @@ func WildcardQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
 }
 
 func TermQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
-	dateQuery := bluge.NewDateRangeQuery(iQuery.Query.StartTime, iQuery.Query.EndTime).SetField("@timestamp")
-
 	var field string
 	if iQuery.Query.Field != "" {
 		field = iQuery.Query.Field
@@ -39,7 +39,10 @@ func TermQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
 	}
 
 	termQuery := bluge.NewTermQuery(iQuery.Query.Term).SetField(field)
-	query := bluge.NewBooleanQuery().AddMust(dateQuery).AddMust(termQuery)
+	query, err := withTimeRange(iQuery, termQuery)
+	if err != nil {
+		return nil, err
+	}
 
 	searchRequest := buildRequest(iQuery, query)
 
@@ -47,15 +50,15 @@ func TermQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
 }
 
 func QueryStringQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
-	options := qs.DefaultOptions()
-	options.WithDefaultAnalyzer(analyzer.NewStandardAnalyzer())
-	userQuery, err := qs.ParseQueryString(iQuery.Query.Term, options)
+	userQuery, err := parseQueryString(iQuery.Query.Term, iQuery.Query.Analyzer, iQuery.FieldAnalyzers)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing query string '%s': %v", iQuery.Query.Term, err)
+		return nil, err
 	}
 
-	dateQuery := bluge.NewDateRangeQuery(iQuery.Query.StartTime, iQuery.Query.EndTime).SetField("@timestamp")
-	finalQuery := bluge.NewBooleanQuery().AddMust(dateQuery).AddMust(userQuery)
+	finalQuery, err := withTimeRange(iQuery, userQuery)
+	if err != nil {
+		return nil, err
+	}
 
 	// sortFields := []string{"-@timestamp"} // adding a - (minus) before the field name will sort the field in descending order
 
@@ -65,8 +68,6 @@ func QueryStringQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
 }
 
 func PrefixQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
-	dateQuery := bluge.NewDateRangeQuery(iQuery.Query.StartTime, iQuery.Query.EndTime).SetField("@timestamp")
-
 	var field string
 	if iQuery.Query.Field != "" {
 		field = iQuery.Query.Field
@@ -75,7 +76,10 @@ func PrefixQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
 	}
 
 	prefixQuery := bluge.NewPrefixQuery(iQuery.Query.Term).SetField(field)
-	query := bluge.NewBooleanQuery().AddMust(dateQuery).AddMust(prefixQuery)
+	query, err := withTimeRange(iQuery, prefixQuery)
+	if err != nil {
+		return nil, err
+	}
 
 	searchRequest := buildRequest(iQuery, query)
 
@@ -83,8 +87,6 @@ func PrefixQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
 }
 
 func MultiPhraseQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
-	dateQuery := bluge.NewDateRangeQuery(iQuery.Query.StartTime, iQuery.Query.EndTime).SetField("@timestamp")
-
 	var field string
 	if iQuery.Query.Field != "" {
 		field = iQuery.Query.Field
@@ -93,7 +95,10 @@ func MultiPhraseQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
 	}
 
 	multiPhraseQuery := bluge.NewMultiPhraseQuery(iQuery.Query.Terms).SetField(field)
-	query := bluge.NewBooleanQuery().AddMust(dateQuery).AddMust(multiPhraseQuery)
+	query, err := withTimeRange(iQuery, multiPhraseQuery)
+	if err != nil {
+		return nil, err
+	}
 
 	searchRequest := buildRequest(iQuery, query)
 
@@ -101,8 +106,6 @@ func MultiPhraseQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
 }
 
 func MatchQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
-	dateQuery := bluge.NewDateRangeQuery(iQuery.Query.StartTime, iQuery.Query.EndTime).SetField("@timestamp")
-	dateQuery.SetField("@timestamp")
 
 	var field string
 	if iQuery.Query.Field != "" {
@@ -111,16 +114,22 @@ func MatchQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
 		field = "_all"
 	}
 
-	matchQuery := bluge.NewMatchQuery(iQuery.Query.Term).SetField(field).SetAnalyzer(analyzer.NewStandardAnalyzer())
-	query := bluge.NewBooleanQuery().AddMust(dateQuery).AddMust(matchQuery)
+	a, err := fieldAnalyzer(iQuery, field)
+	if err != nil {
+		return nil, err
+	}
+
+	matchQuery := bluge.NewMatchQuery(iQuery.Query.Term).SetField(field).SetAnalyzer(a)
+	query, err := withTimeRange(iQuery, matchQuery)
+	if err != nil {
+		return nil, err
+	}
 
 	searchRequest := buildRequest(iQuery, query)
 	return searchRequest, nil
 }
 
 func MatchPhraseQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
-	dateQuery := bluge.NewDateRangeQuery(iQuery.Query.StartTime, iQuery.Query.EndTime).SetField("@timestamp")
-
 	var field string
 	if iQuery.Query.Field != "" {
 		field = iQuery.Query.Field
@@ -128,8 +137,16 @@ func MatchPhraseQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
 		field = "_all"
 	}
 
-	matchPhraseQuery := bluge.NewMatchPhraseQuery(iQuery.Query.Term).SetField(field).SetAnalyzer(analyzer.NewStandardAnalyzer())
-	query := bluge.NewBooleanQuery().AddMust(dateQuery).AddMust(matchPhraseQuery)
+	a, err := fieldAnalyzer(iQuery, field)
+	if err != nil {
+		return nil, err
+	}
+
+	matchPhraseQuery := bluge.NewMatchPhraseQuery(iQuery.Query.Term).SetField(field).SetAnalyzer(a)
+	query, err := withTimeRange(iQuery, matchPhraseQuery)
+	if err != nil {
+		return nil, err
+	}
 
 	searchRequest := buildRequest(iQuery, query)
 
@@ -137,8 +154,6 @@ func MatchPhraseQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
 }
 
 func MatchAllQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
-	dateQuery := bluge.NewDateRangeQuery(iQuery.Query.StartTime, iQuery.Query.EndTime).SetField("@timestamp")
-
 	var field string
 	if iQuery.Query.Field != "" {
 		field = iQuery.Query.Field
@@ -147,7 +162,10 @@ func MatchAllQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
 	}
 
 	fuzzyQuery := bluge.NewFuzzyQuery(iQuery.Query.Term).SetField(field)
-	query := bluge.NewBooleanQuery().AddMust(dateQuery).AddMust(fuzzyQuery)
+	query, err := withTimeRange(iQuery, fuzzyQuery)
+	if err != nil {
+		return nil, err
+	}
 
 	searchRequest := buildRequest(iQuery, query)
 
@@ -155,8 +173,6 @@ func MatchAllQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
 }
 
 func FuzzyQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
-	dateQuery := bluge.NewDateRangeQuery(iQuery.Query.StartTime, iQuery.Query.EndTime).SetField("@timestamp")
-
 	var field string
 	if iQuery.Query.Field != "" {
 		field = iQuery.Query.Field
@@ -165,7 +181,10 @@ func FuzzyQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
 	}
 
 	fuzzyQuery := bluge.NewFuzzyQuery(iQuery.Query.Term).SetField(field)
-	query := bluge.NewBooleanQuery().AddMust(dateQuery).AddMust(fuzzyQuery)
+	query, err := withTimeRange(iQuery, fuzzyQuery)
+	if err != nil {
+		return nil, err
+	}
 
 	searchRequest := buildRequest(iQuery, query)
 
@@ -173,8 +192,10 @@ func FuzzyQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
 }
 
 func DateRangeQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
-	dateQuery := bluge.NewDateRangeQuery(iQuery.Query.StartTime, iQuery.Query.EndTime).SetField("@timestamp")
-	query := bluge.NewBooleanQuery().AddMust(dateQuery)
+	query, err := withTimeRange(iQuery, bluge.NewMatchAllQuery())
+	if err != nil {
+		return nil, err
+	}
 
 	searchRequest := buildRequest(iQuery, query)
 
@@ -182,10 +203,12 @@ func DateRangeQuery(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
 }
 
 func AllDocuments(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
-	dateQuery := bluge.NewDateRangeQuery(iQuery.Query.StartTime, iQuery.Query.EndTime).SetField("@timestamp")
 	allQuery := bluge.NewMatchAllQuery()
 
-	query := bluge.NewBooleanQuery().AddMust(dateQuery).AddMust(allQuery)
+	query, err := withTimeRange(iQuery, allQuery)
+	if err != nil {
+		return nil, err
+	}
 
 	// iQuery.MaxResults = 20
 
@@ -194,10 +217,49 @@ func AllDocuments(iQuery v1.ZincQuery) (bluge.SearchRequest, error) {
 	return searchRequest, nil
 }
 
+// parseQueryString parses the query string, analyzing the terms of each field with the analyzer mapped for it
+func parseQueryString(term, analyzerName string, fieldAnalyzers map[string]string) (bluge.Query, error) {
+	defaultAnalyzer, err := analyzer.Get(analyzerName)
+	if err != nil {
+		return nil, err
+	}
+
+	options := qs.DefaultOptions().WithDefaultAnalyzer(defaultAnalyzer)
+	for field, name := range fieldAnalyzers {
+		a, err := analyzer.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		options = options.WithAnalyzerForField(field, a)
+	}
+
+	query, err := qs.ParseQueryString(term, options)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing query string '%s': %v", term, err)
+	}
+	return query, nil
+}
+
+// fieldAnalyzer returns the analyzer named in the query, or else the analyzer mapped for the field
+func fieldAnalyzer(iQuery v1.ZincQuery, field string) (*analysis.Analyzer, error) {
+	if iQuery.Query.Analyzer != "" {
+		return analyzer.Get(iQuery.Query.Analyzer)
+	}
+
+	return analyzer.Get(iQuery.FieldAnalyzers[field])
+}
+
 // buildRequest combines the ZincQuery with the bluge Query to create a SearchRequest
 func buildRequest(iQuery v1.ZincQuery, query bluge.Query) bluge.SearchRequest {
-	return bluge.NewTopNSearch(iQuery.MaxResults, query).
+	searchRequest := bluge.NewTopNSearch(iQuery.MaxResults, query).
 		SetFrom(iQuery.From).
 		SortBy(iQuery.SortFields).
 		WithStandardAggregations()
+	if len(iQuery.Highlight.Fields) > 0 {
+		searchRequest.IncludeLocations() // the terms that matched, by field
+	}
+	if iQuery.Explain {
+		searchRequest.ExplainScores()
+	}
+	return searchRequest
 }
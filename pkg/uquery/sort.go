@@ -0,0 +1,64 @@
+package uquery
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/blugelabs/bluge"
+	v1 "github.com/prabhatsharma/zinc/pkg/meta/v1"
+)
+
+// tiebreakerField sorts the hits with equal sort values, so that every hit has sort values of its own to search after.
+const tiebreakerField = "_id"
+
+// PagingSortFields returns the sort fields of a search, ending with the _id tiebreaker so that the sort values of its
+// hits can be passed to search_after. Without sort fields the hits are sorted by score, highest first.
+func PagingSortFields(sortFields []string) []string {
+	if len(sortFields) == 0 {
+		sortFields = []string{"-_score"}
+	}
+	if last := sortFields[len(sortFields)-1]; last == tiebreakerField || last == "-"+tiebreakerField {
+		return sortFields
+	}
+	return append(append([]string{}, sortFields...), tiebreakerField)
+}
+
+// EncodeSortValues returns the sort values of a hit as strings, which SearchAfter decodes unchanged.
+func EncodeSortValues(values [][]byte) []string {
+	encoded := make([]string, len(values))
+	for i, value := range values {
+		encoded[i] = base64.RawURLEncoding.EncodeToString(value)
+	}
+	return encoded
+}
+
+// SearchAfter makes the search request return the hits sorted after the hit with the sort values, as returned by
+// EncodeSortValues. The hits with the same sort values are skipped, which the _id tiebreaker avoids.
+func SearchAfter(searchRequest bluge.SearchRequest, iQuery v1.ZincQuery) error {
+	if len(iQuery.SearchAfter) == 0 {
+		return nil
+	}
+	if iQuery.From > 0 {
+		return fmt.Errorf("from must be 0 with search_after")
+	}
+	if len(iQuery.SearchAfter) != len(iQuery.SortFields) {
+		return fmt.Errorf("search_after has %d sort values, the sort has %d fields %v",
+			len(iQuery.SearchAfter), len(iQuery.SortFields), iQuery.SortFields)
+	}
+
+	after := make([][]byte, len(iQuery.SearchAfter))
+	for i, value := range iQuery.SearchAfter {
+		b, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil {
+			return fmt.Errorf("invalid search_after value [%s], use the sort values of a hit", value)
+		}
+		after[i] = b
+	}
+
+	topN, ok := searchRequest.(*bluge.TopNSearch)
+	if !ok {
+		return fmt.Errorf("search_after is not supported by the search")
+	}
+	topN.After(after)
+	return nil
+}
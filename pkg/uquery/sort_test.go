@@ -0,0 +1,153 @@
+package uquery
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/blugelabs/bluge"
+	v1 "github.com/prabhatsharma/zinc/pkg/meta/v1"
+)
+
+func TestPagingSortFields(t *testing.T) {
+	tests := []struct {
+		name       string
+		sortFields []string
+		want       []string
+	}{
+		{"no sort fields", nil, []string{"-_score", "_id"}},
+		{"sort fields", []string{"-@timestamp", "name"}, []string{"-@timestamp", "name", "_id"}},
+		{"ending with _id", []string{"name", "_id"}, []string{"name", "_id"}},
+		{"ending with -_id", []string{"-_id"}, []string{"-_id"}},
+		{"_id before the end", []string{"_id", "name"}, []string{"_id", "name", "_id"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var before []string
+			if tt.sortFields != nil {
+				before = append(before, tt.sortFields...)
+			}
+			if got := PagingSortFields(tt.sortFields); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PagingSortFields(%v) = %v, want %v", tt.sortFields, got, tt.want)
+			}
+			if !reflect.DeepEqual(tt.sortFields, before) {
+				t.Errorf("PagingSortFields() changed its argument to %v", tt.sortFields)
+			}
+		})
+	}
+}
+
+func TestSearchAfterErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   v1.ZincQuery
+		request bluge.SearchRequest
+		wantErr string
+	}{
+		{
+			name:    "with from",
+			query:   v1.ZincQuery{From: 10, SortFields: []string{"_id"}, SearchAfter: []string{"YQ"}},
+			wantErr: "from must be 0 with search_after",
+		},
+		{
+			name:    "sort values and sort fields",
+			query:   v1.ZincQuery{SortFields: []string{"name", "_id"}, SearchAfter: []string{"YQ"}},
+			wantErr: "search_after has 1 sort values, the sort has 2 fields [name _id]",
+		},
+		{
+			name:    "invalid sort value",
+			query:   v1.ZincQuery{SortFields: []string{"_id"}, SearchAfter: []string{"not base64!"}},
+			wantErr: "invalid search_after value [not base64!], use the sort values of a hit",
+		},
+		{
+			name:    "search without top hits",
+			query:   v1.ZincQuery{SortFields: []string{"_id"}, SearchAfter: []string{"YQ"}},
+			request: bluge.NewAllMatches(bluge.NewMatchAllQuery()),
+			wantErr: "search_after is not supported by the search",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := tt.request
+			if request == nil {
+				request = bluge.NewTopNSearch(10, bluge.NewMatchAllQuery())
+			}
+			err := SearchAfter(request, tt.query)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("SearchAfter() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSearchAfterPages(t *testing.T) {
+	writer, err := bluge.OpenWriter(bluge.InMemoryOnlyConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	// Documents with the same name are only told apart by the _id tiebreaker
+	batch := bluge.NewBatch()
+	for id, name := range map[string]string{"1": "b", "2": "a", "3": "b", "4": "c", "5": "b", "6": "a", "7": "b"} {
+		batch.Update(bluge.Identifier(id), bluge.NewDocument(id).AddField(bluge.NewKeywordField("name", name).Sortable()))
+	}
+	if err := writer.Batch(batch); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := writer.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	tests := []struct {
+		sortFields []string
+		want       []string
+	}{
+		{[]string{"name"}, []string{"2", "6", "1", "3", "5", "7", "4"}},
+		{[]string{"-name"}, []string{"4", "1", "3", "5", "7", "2", "6"}},
+		{[]string{"-name", "-_id"}, []string{"4", "7", "5", "3", "1", "6", "2"}},
+	}
+
+	for _, tt := range tests {
+		q := v1.ZincQuery{MaxResults: 2, SortFields: PagingSortFields(tt.sortFields)}
+		var ids []string
+		for page := 0; page < 10; page++ {
+			request := buildRequest(q, bluge.NewMatchAllQuery())
+			if err := SearchAfter(request, q); err != nil {
+				t.Fatalf("%v: SearchAfter() error = %v", tt.sortFields, err)
+			}
+			matches, err := reader.Search(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			n := 0
+			match, err := matches.Next()
+			for err == nil && match != nil {
+				err = match.VisitStoredFields(func(field string, value []byte) bool {
+					if field == "_id" {
+						ids = append(ids, string(value))
+					}
+					return true
+				})
+				q.SearchAfter = EncodeSortValues(match.SortValue)
+				n++
+				match, err = matches.Next()
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n < q.MaxResults {
+				break
+			}
+		}
+
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("pages of %v = %v, want %v", tt.sortFields, ids, tt.want)
+		}
+	}
+}